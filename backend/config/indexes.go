@@ -0,0 +1,151 @@
+package config
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// EnsureIndexes creates the indexes the application relies on.
+// Failures are logged rather than fatal so that a bad index never blocks startup.
+func EnsureIndexes() {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	//Usernames can change, so they are looked up through this index and everything else refers to user_id
+	createIndex(ctx, "user", mongo.IndexModel{
+		Keys:    bson.D{{Key: "username", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	createIndex(ctx, "user", mongo.IndexModel{
+		Keys:    bson.D{{Key: "user_id", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	createIndex(ctx, "user", mongo.IndexModel{
+		Keys: bson.D{{Key: "display_name", Value: 1}},
+	})
+
+	//A friendship is two mirrored documents and never more
+	createIndex(ctx, "friend", mongo.IndexModel{
+		Keys:    bson.D{{Key: "user_id", Value: 1}, {Key: "friend_user_id", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+
+	//At most one pending request per direction; answered requests are kept as history
+	createIndex(ctx, "request", mongo.IndexModel{
+		Keys: bson.D{{Key: "from_id", Value: 1}, {Key: "to_id", Value: 1}},
+		Options: options.Index().
+			SetUnique(true).
+			SetPartialFilterExpression(bson.M{"status": "pending"}),
+	})
+	createIndex(ctx, "request", mongo.IndexModel{
+		Keys: bson.D{{Key: "to_id", Value: 1}, {Key: "status", Value: 1}},
+	})
+	//Expired pending requests and finished rejection cool-downs are removed by MongoDB
+	createIndex(ctx, "request", mongo.IndexModel{
+		Keys:    bson.D{{Key: "expiresAt", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	})
+	createIndex(ctx, "request_log", mongo.IndexModel{
+		Keys:    bson.D{{Key: "created_at", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(int32((24 * time.Hour).Seconds())),
+	})
+	createIndex(ctx, "request_log", mongo.IndexModel{
+		Keys: bson.D{{Key: "from_id", Value: 1}, {Key: "created_at", Value: 1}},
+	})
+
+	createIndex(ctx, "block", mongo.IndexModel{
+		Keys:    bson.D{{Key: "blocker_id", Value: 1}, {Key: "blocked_id", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	createIndex(ctx, "block", mongo.IndexModel{
+		Keys: bson.D{{Key: "blocked_id", Value: 1}},
+	})
+
+	createIndex(ctx, "invite", mongo.IndexModel{
+		Keys:    bson.D{{Key: "token", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	createIndex(ctx, "invite", mongo.IndexModel{
+		Keys: bson.D{{Key: "conversationID", Value: 1}},
+	})
+
+	createIndex(ctx, "conversation", mongo.IndexModel{
+		Keys: bson.D{{Key: "participantIDs", Value: 1}},
+	})
+	createIndex(ctx, "conversation", mongo.IndexModel{
+		Keys: bson.D{{Key: "type", Value: 1}, {Key: "public", Value: 1}, {Key: "lastMessageAt", Value: -1}},
+	})
+
+	createIndex(ctx, "member", mongo.IndexModel{
+		Keys:    bson.D{{Key: "conversationID", Value: 1}, {Key: "user_id", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	createIndex(ctx, "member", mongo.IndexModel{
+		Keys: bson.D{{Key: "user_id", Value: 1}},
+	})
+	createIndex(ctx, "message", mongo.IndexModel{
+		Keys: bson.D{{Key: "senderID", Value: 1}},
+	})
+	createIndex(ctx, "message", mongo.IndexModel{
+		Keys:    bson.D{{Key: "expiresAt", Value: 1}},
+		Options: options.Index().SetSparse(true),
+	})
+	//Full-text search. No stemming, so the terms a search matched can be highlighted as typed
+	createIndex(ctx, "message", mongo.IndexModel{
+		Keys:    bson.D{{Key: "content", Value: "text"}},
+		Options: options.Index().SetDefaultLanguage("none"),
+	})
+	createIndex(ctx, "message", mongo.IndexModel{
+		Keys:    bson.D{{Key: "threadID", Value: 1}, {Key: "created_at", Value: 1}},
+		Options: options.Index().SetSparse(true),
+	})
+
+	createIndex(ctx, "reaction", mongo.IndexModel{
+		Keys:    bson.D{{Key: "message_id", Value: 1}, {Key: "user_id", Value: 1}, {Key: "emoji", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	createIndex(ctx, "reaction", mongo.IndexModel{
+		Keys: bson.D{{Key: "user_id", Value: 1}},
+	})
+
+	createIndex(ctx, "attachment", mongo.IndexModel{
+		Keys:    bson.D{{Key: "attachment_id", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	createIndex(ctx, "attachment", mongo.IndexModel{
+		Keys: bson.D{{Key: "message_id", Value: 1}, {Key: "created_at", Value: 1}},
+	})
+
+	createIndex(ctx, "scheduled", mongo.IndexModel{
+		Keys:    bson.D{{Key: "schedule_id", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	createIndex(ctx, "scheduled", mongo.IndexModel{
+		Keys: bson.D{{Key: "status", Value: 1}, {Key: "send_at", Value: 1}},
+	})
+	createIndex(ctx, "scheduled", mongo.IndexModel{
+		Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "send_at", Value: 1}},
+	})
+
+	createIndex(ctx, "export", mongo.IndexModel{
+		Keys:    bson.D{{Key: "job_id", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	createIndex(ctx, "export", mongo.IndexModel{
+		Keys: bson.D{{Key: "download_token", Value: 1}},
+	})
+	createIndex(ctx, "export", mongo.IndexModel{
+		Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "status", Value: 1}},
+	})
+}
+
+func createIndex(ctx context.Context, collectionName string, model mongo.IndexModel) {
+	if _, err := OpenCollection(collectionName).Indexes().CreateOne(ctx, model); err != nil {
+		log.Printf("Failed to create index on %s: %v", collectionName, err)
+	}
+}
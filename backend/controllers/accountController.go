@@ -0,0 +1,193 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shjung-dev/ChatApplication/backend/config"
+	"github.com/shjung-dev/ChatApplication/backend/helpers"
+	"github.com/shjung-dev/ChatApplication/backend/models"
+	"github.com/shjung-dev/ChatApplication/backend/network"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func DeleteAccount() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+		defer cancel()
+
+		claims, ok := c.Get("claims")
+
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+			return
+		}
+
+		userID := claims.(*helpers.Claims).UserID
+
+		//A stolen access token alone must not be enough to delete an account
+		var body struct {
+			Password string `json:"password"`
+		}
+
+		if err := c.BindJSON(&body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		var user models.User
+
+		userCollection := config.OpenCollection("user")
+		err := userCollection.FindOne(ctx, bson.M{"user_id": userID}).Decode(&user)
+
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		}
+
+		if passwordIsValid, _ := helpers.VerifyPassword(*user.Password, body.Password); !passwordIsValid {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid password"})
+			return
+		}
+
+		if err := helpers.RevokeTokens(ctx, userID); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		network.Disconnect(userID)
+
+		grace := helpers.GetAccountDeletionGrace()
+
+		if grace > 0 {
+			deletionAt := time.Now().Add(grace)
+
+			_, err := userCollection.UpdateOne(ctx, bson.M{"user_id": userID}, bson.M{
+				"$set": bson.M{
+					"deletion_at": deletionAt,
+				},
+			})
+
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+
+			helpers.Audit(ctx, "account_deletion_requested", userID, "scheduled for "+deletionAt.Format(time.RFC3339))
+
+			c.JSON(http.StatusOK, gin.H{
+				"message":     "Account scheduled for deletion. Log in again before then to cancel.",
+				"deletion_at": deletionAt,
+			})
+			return
+		}
+
+		helpers.Audit(ctx, "account_deletion_requested", userID, "immediate")
+
+		if err := helpers.EraseAccount(ctx, user); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Account deleted"})
+	}
+}
+
+func ChangeUsername() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+		defer cancel()
+
+		claims, ok := c.Get("claims")
+
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+			return
+		}
+
+		current := claims.(*helpers.Claims).Ref()
+
+		var body struct {
+			Username string `json:"username"`
+		}
+
+		if err := c.BindJSON(&body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		username := strings.TrimSpace(body.Username)
+
+		if err := validate.Var(username, "required,min=2,max=100"); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "username must be between 2 and 100 characters"})
+			return
+		}
+
+		if username == models.AnnounceUsername || username == models.DeletedUsername {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "username is reserved"})
+			return
+		}
+
+		if username == current.Username {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "That is already your username"})
+			return
+		}
+
+		userCollection := config.OpenCollection("user")
+		count, err := userCollection.CountDocuments(ctx, bson.M{"username": username})
+
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if count > 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "username already exists"})
+			return
+		}
+
+		err = helpers.RenameUser(ctx, current, username)
+
+		//The unique index settles a race for the same name
+		if mongo.IsDuplicateKeyError(err) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "username already exists"})
+			return
+		}
+
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		helpers.Audit(ctx, "username_changed", current.ID, current.Username+" -> "+username)
+
+		//Tokens and the open socket both carry the old name, so replace them
+		accessToken, refreshToken := helpers.GenerateToken(current.ID, username)
+		if err := helpers.UpdateAllToken(accessToken, refreshToken, current.ID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		network.Disconnect(current.ID)
+
+		var user models.User
+
+		err = userCollection.FindOne(ctx, bson.M{"user_id": current.ID}).Decode(&user)
+
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		}
+
+		notifyProfileUpdated(ctx, current.ID, user.Public())
+
+		c.JSON(http.StatusOK, gin.H{
+			"message":       "Username changed",
+			"user":          user.Self(),
+			"access_token":  accessToken,
+			"refresh_token": refreshToken,
+		})
+	}
+}
@@ -0,0 +1,206 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shjung-dev/ChatApplication/backend/config"
+	"github.com/shjung-dev/ChatApplication/backend/helpers"
+	"github.com/shjung-dev/ChatApplication/backend/models"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func UploadAttachment() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+		defer cancel()
+
+		claims, ok := c.Get("claims")
+
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+			return
+		}
+
+		uploader := claims.(*helpers.Claims).UserID
+
+		convo, err := findConversation(ctx, c.Param("convoID"))
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Conversation not found"})
+			return
+		}
+
+		//Uploading follows the same rules as posting a message
+		isMember, err := helpers.IsMember(ctx, convo, uploader)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if !isMember {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Conversation not found"})
+			return
+		}
+
+		if convo.IsChannel() && !convo.IsAdmin(uploader) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Only channel admins can post in this channel"})
+			return
+		}
+
+		//Leave some room for the multipart framing around the file itself
+		maxSize := helpers.GetAttachmentPolicy().MaxSize
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxSize+1<<20)
+
+		header, err := c.FormFile("file")
+		if err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": helpers.ErrAttachmentTooLarge.Error()})
+				return
+			}
+			c.JSON(http.StatusBadRequest, gin.H{"error": "file is required"})
+			return
+		}
+
+		file, err := header.Open()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		defer file.Close()
+
+		attachment, err := helpers.StoreAttachment(ctx, convo.ConversationID, uploader, header.Filename, header.Size, file)
+
+		switch {
+		case errors.Is(err, helpers.ErrAttachmentTooLarge):
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": err.Error()})
+			return
+		case errors.Is(err, helpers.ErrAttachmentType):
+			c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": err.Error()})
+			return
+		case err != nil:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"message":    "Uploaded",
+			"attachment": attachment,
+		})
+	}
+}
+
+// accessibleAttachment loads the attachment if userID may download it. Unsent uploads
+// are private to the uploader, and images only become visible to the rest of the
+// conversation once their metadata has been stripped.
+func accessibleAttachment(ctx context.Context, attachmentID string, userID string) (models.Attachment, bool, error) {
+	var attachment models.Attachment
+
+	attachmentCollection := config.OpenCollection("attachment")
+	err := attachmentCollection.FindOne(ctx, bson.M{"attachment_id": attachmentID}).Decode(&attachment)
+
+	if err != nil {
+		return attachment, false, nil
+	}
+
+	if attachment.UploaderID == userID {
+		return attachment, true, nil
+	}
+
+	if attachment.MessageID == nil || !attachment.Viewable() {
+		return attachment, false, nil
+	}
+
+	convo, err := findConversation(ctx, attachment.ConversationID)
+	if err != nil {
+		return attachment, false, err
+	}
+
+	allowed, err := helpers.IsMember(ctx, convo, userID)
+	return attachment, allowed, err
+}
+
+func streamBlob(ctx context.Context, c *gin.Context, key string, contentType string, size int64, disposition string) {
+	body, err := helpers.GetBlobStore().Get(ctx, key)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Attachment not found"})
+		return
+	}
+
+	defer body.Close()
+
+	c.Header("Content-Type", contentType)
+	if size > 0 {
+		c.Header("Content-Length", strconv.FormatInt(size, 10))
+	}
+	c.Header("Content-Disposition", disposition)
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.Status(http.StatusOK)
+	io.Copy(c.Writer, body)
+}
+
+func DownloadAttachment() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+		defer cancel()
+
+		claims, ok := c.Get("claims")
+
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+			return
+		}
+
+		userID := claims.(*helpers.Claims).UserID
+
+		attachment, allowed, err := accessibleAttachment(ctx, c.Param("attachmentID"), userID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if !allowed {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Attachment not found"})
+			return
+		}
+
+		streamBlob(ctx, c, attachment.StorageKey, attachment.MimeType, attachment.Size,
+			mime.FormatMediaType("attachment", map[string]string{"filename": attachment.Name}))
+	}
+}
+
+func DownloadThumbnail() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+		defer cancel()
+
+		claims, ok := c.Get("claims")
+
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+			return
+		}
+
+		userID := claims.(*helpers.Claims).UserID
+
+		attachment, allowed, err := accessibleAttachment(ctx, c.Param("attachmentID"), userID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if !allowed || attachment.ThumbnailKey == "" {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Thumbnail not found"})
+			return
+		}
+
+		streamBlob(ctx, c, attachment.ThumbnailKey, attachment.ThumbnailType, 0, "inline")
+	}
+}
@@ -0,0 +1,164 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shjung-dev/ChatApplication/backend/config"
+	"github.com/shjung-dev/ChatApplication/backend/helpers"
+	"github.com/shjung-dev/ChatApplication/backend/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func Block() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+		defer cancel()
+
+		claims, ok := c.Get("claims")
+
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+			return
+		}
+
+		blocker := claims.(*helpers.Claims).Ref()
+
+		if blocker.Username == c.Param("username") {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "You cannot block yourself"})
+			return
+		}
+
+		blocked, err := helpers.LookupUser(ctx, c.Param("username"))
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		}
+
+		filter := bson.M{
+			"blocker_id": blocker.ID,
+			"blocked_id": blocked.ID,
+		}
+
+		block := models.Block{
+			ID:        primitive.NewObjectID(),
+			BlockerID: blocker.ID,
+			BlockedID: blocked.ID,
+			Blocker:   blocker.Username,
+			Blocked:   blocked.Username,
+			CreatedAt: time.Now(),
+		}
+
+		blockCollection := config.OpenCollection("block")
+		_, err = blockCollection.UpdateOne(ctx, filter,
+			bson.M{"$setOnInsert": block},
+			options.Update().SetUpsert(true),
+		)
+
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		//Blocking ends the friendship and drops any requests between them
+		if _, err := helpers.RemoveFriendship(ctx, blocker.ID, blocked.ID); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		requestCollection := config.OpenCollection("request")
+		_, err = requestCollection.DeleteMany(ctx, bson.M{
+			"$or": bson.A{
+				bson.M{"from_id": blocker.ID, "to_id": blocked.ID},
+				bson.M{"from_id": blocked.ID, "to_id": blocker.ID},
+			},
+		})
+
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Blocked"})
+	}
+}
+
+func Unblock() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+		defer cancel()
+
+		claims, ok := c.Get("claims")
+
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+			return
+		}
+
+		blocker := claims.(*helpers.Claims).UserID
+		blocked, err := helpers.LookupUser(ctx, c.Param("username"))
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User is not blocked"})
+			return
+		}
+
+		blockCollection := config.OpenCollection("block")
+		result, err := blockCollection.DeleteOne(ctx, bson.M{
+			"blocker_id": blocker,
+			"blocked_id": blocked.ID,
+		})
+
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if result.DeletedCount == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User is not blocked"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Unblocked"})
+	}
+}
+
+func ListBlocked() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+		defer cancel()
+
+		claims, ok := c.Get("claims")
+
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+			return
+		}
+
+		blocker := claims.(*helpers.Claims).UserID
+
+		blockCollection := config.OpenCollection("block")
+		cursor, err := blockCollection.Find(ctx, bson.M{"blocker_id": blocker},
+			options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}}),
+		)
+
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		defer cursor.Close(ctx)
+
+		blocks := []models.Block{}
+
+		if err := cursor.All(ctx, &blocks); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"blocked": blocks})
+	}
+}
@@ -0,0 +1,563 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/shjung-dev/ChatApplication/backend/config"
+	"github.com/shjung-dev/ChatApplication/backend/helpers"
+	"github.com/shjung-dev/ChatApplication/backend/models"
+	"github.com/shjung-dev/ChatApplication/backend/network"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func findConversation(ctx context.Context, convoID string) (models.Conversation, error) {
+	var convo models.Conversation
+
+	convoCollection := config.OpenCollection("conversation")
+	err := convoCollection.FindOne(ctx, bson.M{"conversationID": convoID}).Decode(&convo)
+
+	return convo, err
+}
+
+func CreateChannel() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+		defer cancel()
+
+		claims, ok := c.Get("claims")
+
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+			return
+		}
+
+		creator := claims.(*helpers.Claims).Ref()
+
+		var body struct {
+			Name        string   `json:"name"`
+			Description string   `json:"description"`
+			Public      bool     `json:"public"`
+			Members     []string `json:"members"`
+		}
+
+		if err := c.BindJSON(&body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		name := strings.TrimSpace(body.Name)
+		if name == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Channel name is required"})
+			return
+		}
+
+		found, err := helpers.LookupUsers(ctx, body.Members)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		members := []models.UserRef{creator}
+		for _, m := range found {
+			if m.ID != creator.ID {
+				members = append(members, m)
+			}
+		}
+
+		now := time.Now()
+		convo := models.Conversation{
+			ID:               primitive.NewObjectID(),
+			ConversationID:   uuid.NewString(),
+			ConversationName: &name,
+			Type:             models.ConversationChannel,
+			Description:      strings.TrimSpace(body.Description),
+			Public:           body.Public,
+			ParticipantIDs:   []string{},
+			Participants:     []string{},
+			MemberCount:      len(members),
+			CreatedByID:      creator.ID,
+			CreatedBy:        creator.Username,
+			AdminIDs:         []string{creator.ID},
+			Admins:           []string{creator.Username},
+			CreatedAt:        now,
+			LastMessageAt:    now,
+		}
+
+		docs := make([]interface{}, 0, len(members))
+		for _, m := range members {
+			docs = append(docs, models.Member{
+				ID:             primitive.NewObjectID(),
+				ConversationID: convo.ConversationID,
+				UserID:         m.ID,
+				Username:       m.Username,
+				JoinedAt:       now,
+			})
+		}
+
+		memberCollection := config.OpenCollection("member")
+		if _, err := memberCollection.InsertMany(ctx, docs); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		convoCollection := config.OpenCollection("conversation")
+		if _, err := convoCollection.InsertOne(ctx, convo); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if err := network.PostAnnouncement(convo, creator.Username+" created the channel "+name); err != nil {
+			log.Println("Failed to announce channel:", err)
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"message": "Channel created",
+			"convo":   convo,
+		})
+	}
+}
+
+func AddChannelMembers() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+		defer cancel()
+
+		claims, ok := c.Get("claims")
+
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+			return
+		}
+
+		admin := claims.(*helpers.Claims).UserID
+
+		var body struct {
+			Members []string `json:"members"`
+		}
+
+		if err := c.BindJSON(&body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		convo, err := findConversation(ctx, c.Param("convoID"))
+		if err != nil || !convo.IsChannel() {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Channel not found"})
+			return
+		}
+
+		if !convo.IsAdmin(admin) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Only channel admins can add members"})
+			return
+		}
+
+		members, err := helpers.LookupUsers(ctx, body.Members)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		added := make([]string, 0, len(members))
+		for _, m := range members {
+			convo, err = helpers.AddMember(ctx, convo, m)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			added = append(added, m.Username)
+		}
+
+		for _, m := range members {
+			network.NotifyUser(m.ID, map[string]interface{}{
+				"type":  "conversation_added",
+				"convo": convo,
+			})
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"message": "Members added",
+			"added":   added,
+			"convo":   convo,
+		})
+	}
+}
+
+func RemoveChannelMember() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+		defer cancel()
+
+		claims, ok := c.Get("claims")
+
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+			return
+		}
+
+		admin := claims.(*helpers.Claims).UserID
+
+		convo, err := findConversation(ctx, c.Param("convoID"))
+		if err != nil || !convo.IsChannel() {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Channel not found"})
+			return
+		}
+
+		if !convo.IsAdmin(admin) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Only channel admins can remove members"})
+			return
+		}
+
+		target, err := helpers.LookupUser(ctx, c.Param("username"))
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		}
+
+		if len(convo.AdminIDs) == 1 && convo.AdminIDs[0] == target.ID {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "A channel must keep at least one admin"})
+			return
+		}
+
+		convo, err = helpers.RemoveMember(ctx, convo, target)
+		if errors.Is(err, helpers.ErrNotMember) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User is not a member of this channel"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		network.NotifyUser(target.ID, map[string]interface{}{
+			"type":    "conversation_removed",
+			"convoID": convo.ConversationID,
+		})
+
+		c.JSON(http.StatusOK, gin.H{
+			"message": "Member removed",
+			"convo":   convo,
+		})
+	}
+}
+
+func AddChannelAdmin() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+		defer cancel()
+
+		claims, ok := c.Get("claims")
+
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+			return
+		}
+
+		admin := claims.(*helpers.Claims).UserID
+
+		convo, err := findConversation(ctx, c.Param("convoID"))
+		if err != nil || !convo.IsChannel() {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Channel not found"})
+			return
+		}
+
+		if !convo.IsAdmin(admin) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Only channel admins can promote members"})
+			return
+		}
+
+		target, err := helpers.LookupUser(ctx, c.Param("username"))
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		}
+
+		isMember, err := helpers.IsMember(ctx, convo, target.ID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if !isMember {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Only channel members can become admins"})
+			return
+		}
+
+		convoCollection := config.OpenCollection("conversation")
+		err = convoCollection.FindOneAndUpdate(ctx,
+			bson.M{"conversationID": convo.ConversationID},
+			bson.M{"$addToSet": bson.M{"adminIDs": target.ID, "admins": target.Username}},
+			options.FindOneAndUpdate().SetReturnDocument(options.After),
+		).Decode(&convo)
+
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"message": "Admin added",
+			"convo":   convo,
+		})
+	}
+}
+
+func UpdateChannel() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+		defer cancel()
+
+		claims, ok := c.Get("claims")
+
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+			return
+		}
+
+		admin := claims.(*helpers.Claims).UserID
+
+		//Only the fields that are present are changed
+		var body struct {
+			Name        *string `json:"name"`
+			Description *string `json:"description"`
+			Public      *bool   `json:"public"`
+		}
+
+		if err := c.BindJSON(&body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		convo, err := findConversation(ctx, c.Param("convoID"))
+		if err != nil || !convo.IsChannel() {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Channel not found"})
+			return
+		}
+
+		if !convo.IsAdmin(admin) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Only channel admins can change channel settings"})
+			return
+		}
+
+		set := bson.M{}
+		if body.Name != nil {
+			name := strings.TrimSpace(*body.Name)
+			if name == "" {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Channel name is required"})
+				return
+			}
+			set["conversationName"] = name
+		}
+		if body.Description != nil {
+			set["description"] = strings.TrimSpace(*body.Description)
+		}
+		if body.Public != nil {
+			set["public"] = *body.Public
+		}
+
+		if len(set) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Nothing to update"})
+			return
+		}
+
+		convoCollection := config.OpenCollection("conversation")
+		err = convoCollection.FindOneAndUpdate(ctx,
+			bson.M{"conversationID": convo.ConversationID},
+			bson.M{"$set": set},
+			options.FindOneAndUpdate().SetReturnDocument(options.After),
+		).Decode(&convo)
+
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"message": "Channel updated",
+			"convo":   convo,
+		})
+	}
+}
+
+func ChannelDirectory() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+		defer cancel()
+
+		claims, ok := c.Get("claims")
+
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+			return
+		}
+
+		userID := claims.(*helpers.Claims).UserID
+		page, limit, skip := pagination(c)
+
+		filter := bson.M{
+			"type":   models.ConversationChannel,
+			"public": true,
+		}
+
+		if q := strings.TrimSpace(c.Query("q")); q != "" {
+			pattern := primitive.Regex{Pattern: regexp.QuoteMeta(q), Options: "i"}
+			filter["$or"] = bson.A{
+				bson.M{"conversationName": pattern},
+				bson.M{"description": pattern},
+			}
+		}
+
+		convoCollection := config.OpenCollection("conversation")
+
+		total, err := convoCollection.CountDocuments(ctx, filter)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		//Most recently active channels first
+		cursor, err := convoCollection.Find(ctx, filter, options.Find().
+			SetSort(bson.D{{Key: "lastMessageAt", Value: -1}}).
+			SetSkip(int64(skip)).
+			SetLimit(int64(limit)),
+		)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		defer cursor.Close(ctx)
+
+		var convos []models.Conversation
+
+		if err := cursor.All(ctx, &convos); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		joinedIDs, err := helpers.ChannelIDsForMember(ctx, userID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		joined := make(map[string]bool, len(joinedIDs))
+		for _, id := range joinedIDs {
+			joined[id] = true
+		}
+
+		type DirectoryItem struct {
+			ConversationID string    `json:"conversationID"`
+			Name           string    `json:"name"`
+			Description    string    `json:"description"`
+			MemberCount    int       `json:"memberCount"`
+			LastActivity   time.Time `json:"lastActivity"`
+			Joined         bool      `json:"joined"`
+		}
+
+		channels := make([]DirectoryItem, 0, len(convos))
+		for _, convo := range convos {
+			channels = append(channels, DirectoryItem{
+				ConversationID: convo.ConversationID,
+				Name:           *convo.ConversationName,
+				Description:    convo.Description,
+				MemberCount:    helpers.MemberCount(convo),
+				LastActivity:   convo.LastMessageAt,
+				Joined:         joined[convo.ConversationID],
+			})
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"channels": channels,
+			"page":     page,
+			"limit":    limit,
+			"total":    total,
+		})
+	}
+}
+
+func JoinChannel() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+		defer cancel()
+
+		claims, ok := c.Get("claims")
+
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+			return
+		}
+
+		joiner := claims.(*helpers.Claims).Ref()
+
+		convo, err := findConversation(ctx, c.Param("convoID"))
+
+		//Private channels stay invisible to non-members
+		if err != nil || !convo.IsChannel() || !convo.Public {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Channel not found"})
+			return
+		}
+
+		convo, err = helpers.AddMember(ctx, convo, joiner)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"message": "Joined",
+			"convo":   convo,
+		})
+	}
+}
+
+func LeaveChannel() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+		defer cancel()
+
+		claims, ok := c.Get("claims")
+
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+			return
+		}
+
+		leaver := claims.(*helpers.Claims).Ref()
+
+		convo, err := findConversation(ctx, c.Param("convoID"))
+		if err != nil || !convo.IsChannel() {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Channel not found"})
+			return
+		}
+
+		if len(convo.AdminIDs) == 1 && convo.AdminIDs[0] == leaver.ID && convo.MemberCount > 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Promote another admin before leaving the channel"})
+			return
+		}
+
+		convo, err = helpers.RemoveMember(ctx, convo, leaver)
+		if errors.Is(err, helpers.ErrNotMember) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "You are not a member of this channel"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"message": "Left",
+			"convoID": convo.ConversationID,
+		})
+	}
+}
@@ -0,0 +1,92 @@
+package controllers
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shjung-dev/ChatApplication/backend/helpers"
+	"github.com/shjung-dev/ChatApplication/backend/network"
+)
+
+var expiryLabels = map[string]string{
+	"1h": "1 hour",
+	"1d": "1 day",
+	"1w": "1 week",
+}
+
+func SetMessageExpiry() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+		defer cancel()
+
+		claims, ok := c.Get("claims")
+
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+			return
+		}
+
+		user := claims.(*helpers.Claims).Ref()
+
+		var body struct {
+			Expiry string `json:"expiry"`
+		}
+
+		if err := c.BindJSON(&body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if _, ok := helpers.MessageExpiryOptions[body.Expiry]; !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": helpers.ErrInvalidExpiry.Error()})
+			return
+		}
+
+		convo, err := findConversation(ctx, c.Param("convoID"))
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Conversation not found"})
+			return
+		}
+
+		isMember, err := helpers.IsMember(ctx, convo, user.ID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if !isMember {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Conversation not found"})
+			return
+		}
+
+		if !convo.IsAdmin(user.ID) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Only admins can change disappearing messages"})
+			return
+		}
+
+		ttl, err := helpers.SetMessageExpiry(ctx, convo.ConversationID, body.Expiry)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		//The announcement itself follows the new setting
+		convo.MessageExpiry = int64(ttl / time.Second)
+
+		announcement := user.Username + " turned off disappearing messages"
+		if ttl > 0 {
+			announcement = user.Username + " set messages to disappear after " + expiryLabels[body.Expiry]
+		}
+		if err := network.PostAnnouncement(convo, announcement); err != nil {
+			log.Println("Failed to announce message expiry:", err)
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"message": "Message expiry updated",
+			"convo":   convo,
+		})
+	}
+}
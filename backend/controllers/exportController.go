@@ -0,0 +1,94 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shjung-dev/ChatApplication/backend/config"
+	"github.com/shjung-dev/ChatApplication/backend/helpers"
+	"github.com/shjung-dev/ChatApplication/backend/models"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func RequestExport() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+		defer cancel()
+
+		claims, ok := c.Get("claims")
+
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+			return
+		}
+
+		userID := claims.(*helpers.Claims).UserID
+
+		job, err := helpers.StartExport(ctx, userID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		helpers.Audit(ctx, "data_export_requested", userID, job.JobID)
+
+		c.JSON(http.StatusAccepted, gin.H{
+			"message": "Export started",
+			"job":     job,
+		})
+	}
+}
+
+func ExportStatus() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+		defer cancel()
+
+		claims, ok := c.Get("claims")
+
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+			return
+		}
+
+		userID := claims.(*helpers.Claims).UserID
+
+		var job models.ExportJob
+
+		exportCollection := config.OpenCollection("export")
+		err := exportCollection.FindOne(ctx, bson.M{
+			"job_id":  c.Param("jobID"),
+			"user_id": userID,
+		}).Decode(&job)
+
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Export not found"})
+			return
+		}
+
+		response := gin.H{"job": job}
+		if job.Status == models.ExportReady && job.ExpiresAt != nil && time.Now().Before(*job.ExpiresAt) {
+			response["download_url"] = "/export/" + job.DownloadToken
+		}
+
+		c.JSON(http.StatusOK, response)
+	}
+}
+
+// DownloadExport serves an archive by its secret token so the link works from a plain browser download.
+func DownloadExport() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+		defer cancel()
+
+		job, err := helpers.FindExportByToken(ctx, c.Param("token"))
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Download link is invalid or has expired"})
+			return
+		}
+
+		c.FileAttachment(job.FilePath, "chat-data-export.zip")
+	}
+}
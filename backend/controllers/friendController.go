@@ -8,7 +8,7 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/shjung-dev/ChatApplication/backend/config"
 	"github.com/shjung-dev/ChatApplication/backend/helpers"
-	"github.com/shjung-dev/ChatApplication/backend/models"
+	"github.com/shjung-dev/ChatApplication/backend/network"
 	"go.mongodb.org/mongo-driver/bson"
 )
 
@@ -24,27 +24,30 @@ func Reject() gin.HandlerFunc {
 			return
 		}
 
-		person_rejecting := claims.(*helpers.Claims).Username
-		person_getting_rejected := c.Param("receiver")
-
-		filter := bson.M{
-			"from": person_getting_rejected,
-			"to":   person_rejecting,
+		person_rejecting := claims.(*helpers.Claims).Ref()
+		person_getting_rejected, err := helpers.LookupUser(ctx, c.Param("receiver"))
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
 		}
 
-		requestCollection := config.OpenCollection("request")
-
-		result, err := requestCollection.DeleteOne(ctx, filter)
+		rejected, err := helpers.RejectFriendRequest(ctx, person_getting_rejected.ID, person_rejecting.ID)
 		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": err})
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
 
-		if result.DeletedCount == 0 {
+		if !rejected {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "No document found to be deleted"})
 			return
 		}
 
+		network.NotifyUser(person_getting_rejected.ID, network.OutgoingMessage{
+			From: person_rejecting.Username,
+			To:   person_getting_rejected.Username,
+			Type: "friend_rejected",
+		})
+
 		c.JSON(http.StatusOK, gin.H{
 			"message": "Rejected",
 		})
@@ -64,52 +67,93 @@ func Accept() gin.HandlerFunc {
 			return
 		}
 
-		accepter := claims.(*helpers.Claims).Username
-		sender := c.Param("username")
-
-		friend := models.Friend{
-			Username:       &accepter,
-			FriendUsername: &sender,
+		accepter := claims.(*helpers.Claims).Ref()
+		sender, err := helpers.LookupUser(ctx, c.Param("username"))
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
 		}
 
-		friendCollection := config.OpenCollection("friend")
-		_, insertErr := friendCollection.InsertOne(ctx, friend)
+		accepted, err := helpers.AcceptFriendRequest(ctx, sender, accepter)
 
-		if insertErr != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": insertErr.Error()})
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
 
-		filter := bson.M{
-			"from": sender,
-			"to":   accepter,
+		if !accepted {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Friend request not found"})
+			return
 		}
 
-		update := bson.M{
-			"$set": bson.M{
-				"status": "accepted",
-			},
-		}
+		network.NotifyUser(sender.ID, network.OutgoingMessage{
+			From: accepter.Username,
+			To:   sender.Username,
+			Type: "friend_accepted",
+		})
 
-		requestCollection := config.OpenCollection("request")
+		c.JSON(http.StatusOK, gin.H{"message": "Accepted"})
+	}
+}
 
-		result, err := requestCollection.UpdateOne(ctx, filter, update)
+func Remove() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+		defer cancel()
 
+		claims, ok := c.Get("claims")
+
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+			return
+		}
+
+		person_removing := claims.(*helpers.Claims).Ref()
+		person_getting_removed, err := helpers.LookupUser(ctx, c.Param("username"))
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		}
+
+		deleted, err := helpers.RemoveFriendship(ctx, person_removing.ID, person_getting_removed.ID)
 		if err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
 
-		if result.MatchedCount == 0 {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Friend request not found"})
+		if deleted == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "No friend document found to be deleted"})
 			return
 		}
 
-		c.JSON(http.StatusOK, gin.H{"message": "Accepted"})
+		//Either of them may have sent the original request
+		filter := bson.M{
+			"$or": bson.A{
+				bson.M{"from_id": person_getting_removed.ID, "to_id": person_removing.ID},
+				bson.M{"from_id": person_removing.ID, "to_id": person_getting_removed.ID},
+			},
+		}
+
+		requestCollection := config.OpenCollection("request")
+
+		if _, err := requestCollection.DeleteMany(ctx, filter); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		network.NotifyUser(person_getting_removed.ID, network.OutgoingMessage{
+			From: person_removing.Username,
+			To:   person_getting_removed.Username,
+			Type: "friend_removed",
+		})
+
+		c.JSON(http.StatusOK, gin.H{
+			"message": "Friend removed and Request Record Deleted",
+		})
 	}
 }
 
-func Remove() gin.HandlerFunc {
+func OutgoingRequests() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
 		defer cancel()
@@ -121,47 +165,65 @@ func Remove() gin.HandlerFunc {
 			return
 		}
 
-		person_removing := claims.(*helpers.Claims).Username
-		person_getting_removed := c.Param("username")
+		sender := claims.(*helpers.Claims).UserID
 
-		filterOne := bson.M{
-			"username":       person_removing,
-			"friendusername": person_getting_removed,
+		requests, err := helpers.OutgoingRequests(ctx, sender)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
 		}
 
-		filterTwo := bson.M{
-			"from": person_getting_removed,
-			"to":   person_removing,
-		}
+		c.JSON(http.StatusOK, gin.H{"requests": requests})
+	}
+}
 
-		friendCollection := config.OpenCollection("friend")
+func Cancel() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+		defer cancel()
 
-		result, err := friendCollection.DeleteOne(ctx, filterOne)
-		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": err})
+		claims, ok := c.Get("claims")
+
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
 			return
 		}
 
-		if result.DeletedCount == 0 {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "No friend document found to be deleted"})
+		person_cancelling := claims.(*helpers.Claims).Ref()
+		receiver, err := helpers.LookupUser(ctx, c.Param("receiver"))
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
 			return
 		}
 
+		//Only a request that hasn't been answered yet can be withdrawn
+		filter := bson.M{
+			"from_id": person_cancelling.ID,
+			"to_id":   receiver.ID,
+			"status":  "pending",
+		}
+
 		requestCollection := config.OpenCollection("request")
 
-		result, e := requestCollection.DeleteOne(ctx, filterTwo)
-		if e != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": e})
+		result, err := requestCollection.DeleteOne(ctx, filter)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
 
 		if result.DeletedCount == 0 {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "No request document found to be deleted"})
+			c.JSON(http.StatusNotFound, gin.H{"error": "No pending request found to be cancelled"})
 			return
 		}
 
+		network.NotifyUser(receiver.ID, network.OutgoingMessage{
+			From: person_cancelling.Username,
+			To:   receiver.Username,
+			Type: "friend_request_cancelled",
+		})
+
 		c.JSON(http.StatusOK, gin.H{
-			"message": "Friend removed and Request Record Deleted",
+			"message": "Cancelled",
 		})
 	}
 }
@@ -0,0 +1,442 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/shjung-dev/ChatApplication/backend/config"
+	"github.com/shjung-dev/ChatApplication/backend/helpers"
+	"github.com/shjung-dev/ChatApplication/backend/models"
+	"github.com/shjung-dev/ChatApplication/backend/network"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func CreateInvite() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+		defer cancel()
+
+		claims, ok := c.Get("claims")
+
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+			return
+		}
+
+		creator := claims.(*helpers.Claims).Ref()
+		convoID := c.Param("convoID")
+
+		//Both fields are optional -> an empty body creates a link that never expires with unlimited uses
+		var body struct {
+			ExpiresInMinutes int `json:"expiresInMinutes"`
+			MaxUses          int `json:"maxUses"`
+		}
+
+		if err := c.ShouldBindJSON(&body); err != nil && !errors.Is(err, io.EOF) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if body.ExpiresInMinutes < 0 || body.MaxUses < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "expiresInMinutes and maxUses must not be negative"})
+			return
+		}
+
+		var convo models.Conversation
+
+		convoCollection := config.OpenCollection("conversation")
+		err := convoCollection.FindOne(ctx, bson.M{"conversationID": convoID}).Decode(&convo)
+
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Conversation not found"})
+			return
+		}
+
+		if !convo.IsGroup() && !convo.IsChannel() {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invite links are only available for group chats and channels"})
+			return
+		}
+
+		if !convo.IsAdmin(creator.ID) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Only admins can create invite links"})
+			return
+		}
+
+		invite := models.Invite{
+			ID:             primitive.NewObjectID(),
+			Token:          uuid.NewString(),
+			ConversationID: convo.ConversationID,
+			CreatedByID:    creator.ID,
+			CreatedBy:      creator.Username,
+			MaxUses:        body.MaxUses,
+			CreatedAt:      time.Now(),
+		}
+
+		if body.ExpiresInMinutes > 0 {
+			expiresAt := invite.CreatedAt.Add(time.Duration(body.ExpiresInMinutes) * time.Minute)
+			invite.ExpiresAt = &expiresAt
+		}
+
+		inviteCollection := config.OpenCollection("invite")
+		_, insertErr := inviteCollection.InsertOne(ctx, invite)
+
+		if insertErr != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": insertErr.Error()})
+			return
+		}
+
+		if err := network.PostAnnouncement(convo, creator.Username+" created an invite link"); err != nil {
+			log.Println("Failed to announce invite:", err)
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"message": "Invite created",
+			"invite":  invite,
+		})
+	}
+}
+
+func ListInvites() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+		defer cancel()
+
+		claims, ok := c.Get("claims")
+
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+			return
+		}
+
+		userID := claims.(*helpers.Claims).UserID
+		convoID := c.Param("convoID")
+
+		var convo models.Conversation
+
+		convoCollection := config.OpenCollection("conversation")
+		err := convoCollection.FindOne(ctx, bson.M{"conversationID": convoID}).Decode(&convo)
+
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Conversation not found"})
+			return
+		}
+
+		if !convo.IsAdmin(userID) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Only admins can view invite links"})
+			return
+		}
+
+		//Only links that can still be used are outstanding
+		inviteCollection := config.OpenCollection("invite")
+		cursor, err := inviteCollection.Find(ctx, bson.M{
+			"conversationID": convo.ConversationID,
+			"revoked":        false,
+		})
+
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		defer cursor.Close(ctx)
+
+		var invites []models.Invite
+
+		if err := cursor.All(ctx, &invites); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		now := time.Now()
+		outstanding := []models.Invite{}
+		for _, invite := range invites {
+			if invite.Usable(now) {
+				outstanding = append(outstanding, invite)
+			}
+		}
+
+		c.JSON(http.StatusOK, gin.H{"invites": outstanding})
+	}
+}
+
+func PreviewInvite() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+		defer cancel()
+
+		claims, ok := c.Get("claims")
+
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+			return
+		}
+
+		userID := claims.(*helpers.Claims).UserID
+		token := c.Param("token")
+
+		var invite models.Invite
+
+		inviteCollection := config.OpenCollection("invite")
+		err := inviteCollection.FindOne(ctx, bson.M{"token": token}).Decode(&invite)
+
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Invite not found"})
+			return
+		}
+
+		var convo models.Conversation
+
+		convoCollection := config.OpenCollection("conversation")
+		err = convoCollection.FindOne(ctx, bson.M{"conversationID": invite.ConversationID}).Decode(&convo)
+
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Conversation not found"})
+			return
+		}
+
+		alreadyMember, err := helpers.IsMember(ctx, convo, userID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"groupName":     convo.ConversationName,
+			"type":          convo.Kind(),
+			"createdBy":     invite.CreatedBy,
+			"memberCount":   helpers.MemberCount(convo),
+			"expiresAt":     invite.ExpiresAt,
+			"maxUses":       invite.MaxUses,
+			"uses":          invite.Uses,
+			"valid":         invite.Usable(time.Now()),
+			"alreadyMember": alreadyMember,
+		})
+	}
+}
+
+func JoinInvite() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+		defer cancel()
+
+		claims, ok := c.Get("claims")
+
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+			return
+		}
+
+		joiner := claims.(*helpers.Claims).Ref()
+		token := c.Param("token")
+
+		var invite models.Invite
+
+		inviteCollection := config.OpenCollection("invite")
+		err := inviteCollection.FindOne(ctx, bson.M{"token": token}).Decode(&invite)
+
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Invite not found"})
+			return
+		}
+
+		var convo models.Conversation
+
+		convoCollection := config.OpenCollection("conversation")
+		err = convoCollection.FindOne(ctx, bson.M{"conversationID": invite.ConversationID}).Decode(&convo)
+
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Conversation not found"})
+			return
+		}
+
+		alreadyMember, err := helpers.IsMember(ctx, convo, joiner.ID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		//Joining a group you are already in should not use up the link
+		if alreadyMember {
+			c.JSON(http.StatusOK, gin.H{
+				"message": "Already a member",
+				"convo":   convo,
+			})
+			return
+		}
+
+		//Claim one use atomically so concurrent joins cannot exceed maxUses
+		now := time.Now()
+		filter := bson.M{
+			"token":   token,
+			"revoked": false,
+			"$and": bson.A{
+				bson.M{"$or": bson.A{
+					bson.M{"expiresAt": bson.M{"$exists": false}},
+					bson.M{"expiresAt": bson.M{"$gt": now}},
+				}},
+				bson.M{"$or": bson.A{
+					bson.M{"maxUses": 0},
+					bson.M{"$expr": bson.M{"$lt": bson.A{"$uses", "$maxUses"}}},
+				}},
+			},
+		}
+
+		err = inviteCollection.FindOneAndUpdate(ctx, filter, bson.M{"$inc": bson.M{"uses": 1}}).Err()
+
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			c.JSON(http.StatusGone, gin.H{"error": "Invite link has expired, been revoked or reached its usage limit"})
+			return
+		}
+
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		convo, err = helpers.AddMember(ctx, convo, joiner)
+
+		if err != nil {
+			//Give the use back so a failed join doesn't spend the invite
+			if _, undoErr := inviteCollection.UpdateOne(ctx, bson.M{"token": token}, bson.M{"$inc": bson.M{"uses": -1}}); undoErr != nil {
+				log.Println("Failed to release invite use:", undoErr)
+			}
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if err := network.PostAnnouncement(convo, joiner.Username+" joined via an invite link"); err != nil {
+			log.Println("Failed to announce join:", err)
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"message": "Joined",
+			"convo":   convo,
+		})
+	}
+}
+
+func RevokeInvite() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+		defer cancel()
+
+		claims, ok := c.Get("claims")
+
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+			return
+		}
+
+		revoker := claims.(*helpers.Claims).Ref()
+		token := c.Param("token")
+
+		var invite models.Invite
+
+		inviteCollection := config.OpenCollection("invite")
+		err := inviteCollection.FindOne(ctx, bson.M{"token": token}).Decode(&invite)
+
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Invite not found"})
+			return
+		}
+
+		var convo models.Conversation
+
+		convoCollection := config.OpenCollection("conversation")
+		err = convoCollection.FindOne(ctx, bson.M{"conversationID": invite.ConversationID}).Decode(&convo)
+
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Conversation not found"})
+			return
+		}
+
+		if !convo.IsAdmin(revoker.ID) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Only admins can revoke invite links"})
+			return
+		}
+
+		if invite.Revoked {
+			c.JSON(http.StatusOK, gin.H{"message": "Already revoked"})
+			return
+		}
+
+		_, err = inviteCollection.UpdateOne(ctx, bson.M{"token": token}, bson.M{
+			"$set": bson.M{
+				"revoked": true,
+			},
+		})
+
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if err := network.PostAnnouncement(convo, revoker.Username+" revoked an invite link"); err != nil {
+			log.Println("Failed to announce revocation:", err)
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Revoked"})
+	}
+}
+
+func RevokeAllInvites() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+		defer cancel()
+
+		claims, ok := c.Get("claims")
+
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+			return
+		}
+
+		revoker := claims.(*helpers.Claims).Ref()
+		convoID := c.Param("convoID")
+
+		var convo models.Conversation
+
+		convoCollection := config.OpenCollection("conversation")
+		err := convoCollection.FindOne(ctx, bson.M{"conversationID": convoID}).Decode(&convo)
+
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Conversation not found"})
+			return
+		}
+
+		if !convo.IsAdmin(revoker.ID) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Only admins can revoke invite links"})
+			return
+		}
+
+		inviteCollection := config.OpenCollection("invite")
+		result, err := inviteCollection.UpdateMany(ctx,
+			bson.M{"conversationID": convo.ConversationID, "revoked": false},
+			bson.M{"$set": bson.M{"revoked": true}},
+		)
+
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if result.ModifiedCount > 0 {
+			if err := network.PostAnnouncement(convo, revoker.Username+" revoked all invite links"); err != nil {
+				log.Println("Failed to announce revocation:", err)
+			}
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"message": "Revoked",
+			"revoked": result.ModifiedCount,
+		})
+	}
+}
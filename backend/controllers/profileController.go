@@ -0,0 +1,255 @@
+package controllers
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shjung-dev/ChatApplication/backend/config"
+	"github.com/shjung-dev/ChatApplication/backend/helpers"
+	"github.com/shjung-dev/ChatApplication/backend/models"
+	"github.com/shjung-dev/ChatApplication/backend/network"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	maxDisplayNameLength = 100
+	maxBioLength         = 500
+	maxStatusLength      = 140
+)
+
+// publicUserProjection loads only the fields needed to build a models.PublicUser.
+var publicUserProjection = bson.M{
+	"user_id":           1,
+	"username":          1,
+	"display_name":      1,
+	"avatar_url":        1,
+	"bio":               1,
+	"status_text":       1,
+	"status_expires_at": 1,
+}
+
+func GetMyProfile() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+		defer cancel()
+
+		claims, ok := c.Get("claims")
+
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+			return
+		}
+
+		userID := claims.(*helpers.Claims).UserID
+
+		var user models.User
+
+		userCollection := config.OpenCollection("user")
+		err := userCollection.FindOne(ctx, bson.M{"user_id": userID},
+			options.FindOne().SetProjection(publicUserProjection),
+		).Decode(&user)
+
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"profile": user.Public()})
+	}
+}
+
+func GetProfile() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+		defer cancel()
+
+		claims, ok := c.Get("claims")
+
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+			return
+		}
+
+		viewer := claims.(*helpers.Claims).UserID
+
+		var user models.User
+
+		userCollection := config.OpenCollection("user")
+		err := userCollection.FindOne(ctx, bson.M{"username": c.Param("username")},
+			options.FindOne().SetProjection(publicUserProjection),
+		).Decode(&user)
+
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		}
+
+		blocked, err := helpers.IsBlockedEitherWay(ctx, viewer, user.User_id)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if blocked {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"profile": user.Public()})
+	}
+}
+
+func UpdateProfile() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+		defer cancel()
+
+		claims, ok := c.Get("claims")
+
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+			return
+		}
+
+		userID := claims.(*helpers.Claims).UserID
+
+		//Only the fields that are present are changed; an empty string clears a field
+		var body struct {
+			DisplayName            *string `json:"display_name"`
+			AvatarURL              *string `json:"avatar_url"`
+			Bio                    *string `json:"bio"`
+			StatusText             *string `json:"status_text"`
+			StatusExpiresInMinutes int     `json:"status_expires_in_minutes"`
+		}
+
+		if err := c.BindJSON(&body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		set := bson.M{}
+		unset := bson.M{}
+
+		setOrClear := func(field string, value *string, maxLength int) bool {
+			if value == nil {
+				return true
+			}
+			v := strings.TrimSpace(*value)
+			if utf8.RuneCountInString(v) > maxLength {
+				c.JSON(http.StatusBadRequest, gin.H{"error": field + " is too long"})
+				return false
+			}
+			if v == "" {
+				unset[field] = ""
+			} else {
+				set[field] = v
+			}
+			return true
+		}
+
+		if !setOrClear("display_name", body.DisplayName, maxDisplayNameLength) ||
+			!setOrClear("bio", body.Bio, maxBioLength) ||
+			!setOrClear("status_text", body.StatusText, maxStatusLength) {
+			return
+		}
+
+		if body.AvatarURL != nil {
+			avatar := strings.TrimSpace(*body.AvatarURL)
+			if avatar == "" {
+				unset["avatar_url"] = ""
+			} else if u, err := url.Parse(avatar); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "avatar_url must be an http(s) URL"})
+				return
+			} else {
+				set["avatar_url"] = avatar
+			}
+		}
+
+		//A new status replaces the old expiry as well
+		if body.StatusText != nil {
+			if body.StatusExpiresInMinutes > 0 {
+				set["status_expires_at"] = time.Now().Add(time.Duration(body.StatusExpiresInMinutes) * time.Minute)
+			} else {
+				unset["status_expires_at"] = ""
+			}
+		}
+
+		if len(set) == 0 && len(unset) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Nothing to update"})
+			return
+		}
+
+		set["updated_at"] = time.Now()
+		update := bson.M{"$set": set}
+		if len(unset) > 0 {
+			update["$unset"] = unset
+		}
+
+		var user models.User
+
+		userCollection := config.OpenCollection("user")
+		err := userCollection.FindOneAndUpdate(ctx, bson.M{"user_id": userID}, update,
+			options.FindOneAndUpdate().
+				SetProjection(publicUserProjection).
+				SetReturnDocument(options.After),
+		).Decode(&user)
+
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		profile := user.Public()
+		notifyProfileUpdated(ctx, userID, profile)
+
+		c.JSON(http.StatusOK, gin.H{
+			"message": "Profile updated",
+			"profile": profile,
+		})
+	}
+}
+
+// notifyProfileUpdated pushes the new profile to friends and conversation partners
+// so their clients can refresh names and avatars without reloading.
+func notifyProfileUpdated(ctx context.Context, userID string, profile models.PublicUser) {
+	friends, err := helpers.FriendIDs(ctx, userID)
+	if err != nil {
+		log.Println("Failed to fetch friends:", err)
+		return
+	}
+
+	partners, err := helpers.ConversationPartners(ctx, userID)
+	if err != nil {
+		log.Println("Failed to fetch conversation partners:", err)
+		return
+	}
+
+	blocked, err := helpers.BlockedUserIDs(ctx, userID)
+	if err != nil {
+		log.Println("Failed to fetch blocks:", err)
+		return
+	}
+
+	skip := make(map[string]bool, len(blocked))
+	for _, b := range blocked {
+		skip[b] = true
+	}
+
+	event := map[string]interface{}{
+		"type": "profile_updated",
+		"user": profile,
+	}
+
+	for _, u := range helpers.Unique(append(friends, partners...)) {
+		if !skip[u] {
+			network.NotifyUser(u, event)
+		}
+	}
+}
@@ -0,0 +1,187 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shjung-dev/ChatApplication/backend/helpers"
+	"github.com/shjung-dev/ChatApplication/backend/models"
+)
+
+// scheduledError maps the errors of the scheduled-message helpers to a response.
+func scheduledError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, helpers.ErrScheduleInPast), errors.Is(err, helpers.ErrScheduleTooFar):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, helpers.ErrTooManyScheduled):
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+	case errors.Is(err, helpers.ErrScheduledNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Scheduled message not found"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+func ScheduleMessage() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+		defer cancel()
+
+		claims, ok := c.Get("claims")
+
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+			return
+		}
+
+		userID := claims.(*helpers.Claims).UserID
+
+		var body struct {
+			Content     string    `json:"content"`
+			SendAt      time.Time `json:"send_at"`
+			Attachments []string  `json:"attachments"`
+			ReplyTo     string    `json:"replyTo"`
+			ThreadID    string    `json:"threadID"`
+		}
+
+		if err := c.BindJSON(&body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if strings.TrimSpace(body.Content) == "" && len(body.Attachments) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "content or attachments are required"})
+			return
+		}
+
+		convo, err := findConversation(ctx, c.Param("convoID"))
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Conversation not found"})
+			return
+		}
+
+		//Checked again when the message is sent, since either can change in the meantime
+		isMember, err := helpers.IsMember(ctx, convo, userID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if !isMember {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Conversation not found"})
+			return
+		}
+
+		if convo.IsChannel() && !convo.IsAdmin(userID) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Only channel admins can post in this channel"})
+			return
+		}
+
+		scheduled, err := helpers.ScheduleMessage(ctx, models.ScheduledMessage{
+			UserID:         userID,
+			ConversationID: convo.ConversationID,
+			Content:        body.Content,
+			Attachments:    body.Attachments,
+			ReplyTo:        body.ReplyTo,
+			ThreadID:       body.ThreadID,
+			SendAt:         body.SendAt,
+		})
+		if err != nil {
+			scheduledError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{
+			"message":   "Message scheduled",
+			"scheduled": scheduled,
+		})
+	}
+}
+
+func ListScheduledMessages() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+		defer cancel()
+
+		claims, ok := c.Get("claims")
+
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+			return
+		}
+
+		scheduled, err := helpers.ListScheduled(ctx, claims.(*helpers.Claims).UserID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"scheduled": scheduled})
+	}
+}
+
+func UpdateScheduledMessage() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+		defer cancel()
+
+		claims, ok := c.Get("claims")
+
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+			return
+		}
+
+		//Omitted fields are left as they are
+		var body struct {
+			Content *string    `json:"content"`
+			SendAt  *time.Time `json:"send_at"`
+		}
+
+		if err := c.BindJSON(&body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if body.Content == nil && body.SendAt == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "content or send_at is required"})
+			return
+		}
+
+		scheduled, err := helpers.UpdateScheduled(ctx, claims.(*helpers.Claims).UserID, c.Param("scheduleID"), body.Content, body.SendAt)
+		if err != nil {
+			scheduledError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"message":   "Scheduled message updated",
+			"scheduled": scheduled,
+		})
+	}
+}
+
+func CancelScheduledMessage() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+		defer cancel()
+
+		claims, ok := c.Get("claims")
+
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+			return
+		}
+
+		if err := helpers.CancelScheduled(ctx, claims.(*helpers.Claims).UserID, c.Param("scheduleID")); err != nil {
+			scheduledError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Scheduled message cancelled"})
+	}
+}
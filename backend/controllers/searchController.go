@@ -0,0 +1,92 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shjung-dev/ChatApplication/backend/helpers"
+)
+
+// parseSearchDate accepts either a full RFC 3339 timestamp or a plain date.
+// A plain date used as an upper bound covers that whole day.
+func parseSearchDate(value string, endOfDay bool) (time.Time, bool) {
+	if value == "" {
+		return time.Time{}, true
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, true
+	}
+	t, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return time.Time{}, false
+	}
+	if endOfDay {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t, true
+}
+
+func SearchMessages() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+		defer cancel()
+
+		claims, ok := c.Get("claims")
+
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+			return
+		}
+
+		userID := claims.(*helpers.Claims).UserID
+		page, limit, skip := pagination(c)
+
+		query := strings.TrimSpace(c.Query("q"))
+		if query == "" || len(query) > 200 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "q must be between 1 and 200 characters"})
+			return
+		}
+
+		from, okFrom := parseSearchDate(c.Query("from"), false)
+		to, okTo := parseSearchDate(c.Query("to"), true)
+		if !okFrom || !okTo {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "from and to must be dates (2006-01-02) or RFC 3339 timestamps"})
+			return
+		}
+
+		search := helpers.MessageSearch{
+			UserID:         userID,
+			Query:          query,
+			ConversationID: c.Query("convoID"),
+			From:           from,
+			To:             to,
+			Skip:           skip,
+			Limit:          limit,
+		}
+
+		if sender := c.Query("sender"); sender != "" {
+			ref, err := helpers.LookupUser(ctx, sender)
+			if err != nil {
+				c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+				return
+			}
+			search.SenderID = ref.ID
+		}
+
+		results, total, err := helpers.SearchMessages(ctx, search)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"results": results,
+			"page":    page,
+			"limit":   limit,
+			"total":   total,
+		})
+	}
+}
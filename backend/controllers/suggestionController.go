@@ -0,0 +1,177 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shjung-dev/ChatApplication/backend/config"
+	"github.com/shjung-dev/ChatApplication/backend/helpers"
+	"github.com/shjung-dev/ChatApplication/backend/models"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+type Suggestion struct {
+	UserID        string `json:"user_id"`
+	Username      string `json:"username"`
+	MutualFriends int    `json:"mutualFriends"`
+	SharedGroups  int    `json:"sharedGroups"`
+}
+
+func FriendSuggestions() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+		defer cancel()
+
+		claims, ok := c.Get("claims")
+
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+			return
+		}
+
+		userID := claims.(*helpers.Claims).UserID
+
+		limit, err := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(defaultPageSize)))
+		if err != nil || limit < 1 || limit > maxPageSize {
+			limit = defaultPageSize
+		}
+
+		friends, err := helpers.FriendIDs(ctx, userID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		//Everyone who must never be suggested
+		excluded := map[string]bool{userID: true}
+		for _, f := range friends {
+			excluded[f] = true
+		}
+
+		blocked, err := helpers.BlockedUserIDs(ctx, userID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		for _, b := range blocked {
+			excluded[b] = true
+		}
+
+		requestCollection := config.OpenCollection("request")
+		cursor, err := requestCollection.Find(ctx, bson.M{
+			"status": "pending",
+			"$or": bson.A{
+				bson.M{"from_id": userID},
+				bson.M{"to_id": userID},
+			},
+		})
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		var requests []models.Request
+
+		if err := cursor.All(ctx, &requests); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		for _, r := range requests {
+			excluded[r.FromID] = true
+			excluded[r.ToID] = true
+		}
+
+		suggestions := make(map[string]*Suggestion)
+		candidate := func(id string, name string) *Suggestion {
+			s, ok := suggestions[id]
+			if !ok {
+				s = &Suggestion{UserID: id, Username: name}
+				suggestions[id] = s
+			}
+			return s
+		}
+
+		//Friends of my friends, counted once per mutual friend
+		friendCollection := config.OpenCollection("friend")
+		cursor, err = friendCollection.Aggregate(ctx, bson.A{
+			bson.M{"$match": bson.M{"user_id": bson.M{"$in": friends}}},
+			bson.M{"$group": bson.M{
+				"_id":      "$friend_user_id",
+				"username": bson.M{"$first": "$friendusername"},
+				"count":    bson.M{"$sum": 1},
+			}},
+		})
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		var mutuals []struct {
+			UserID   string `bson:"_id"`
+			Username string `bson:"username"`
+			Count    int    `bson:"count"`
+		}
+
+		if err := cursor.All(ctx, &mutuals); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		for _, m := range mutuals {
+			if !excluded[m.UserID] {
+				candidate(m.UserID, m.Username).MutualFriends = m.Count
+			}
+		}
+
+		//People I share group chats with
+		convoCollection := config.OpenCollection("conversation")
+		cursor, err = convoCollection.Find(ctx, bson.M{"participantIDs": userID})
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		var convos []models.Conversation
+
+		if err := cursor.All(ctx, &convos); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		for _, convo := range convos {
+			if !convo.IsGroup() {
+				continue
+			}
+			for i, p := range convo.ParticipantIDs {
+				if !excluded[p] && i < len(convo.Participants) {
+					candidate(p, convo.Participants[i]).SharedGroups++
+				}
+			}
+		}
+
+		result := make([]Suggestion, 0, len(suggestions))
+		for _, s := range suggestions {
+			result = append(result, *s)
+		}
+
+		sort.Slice(result, func(i, j int) bool {
+			si := result[i].MutualFriends + result[i].SharedGroups
+			sj := result[j].MutualFriends + result[j].SharedGroups
+			if si != sj {
+				return si > sj
+			}
+			if result[i].MutualFriends != result[j].MutualFriends {
+				return result[i].MutualFriends > result[j].MutualFriends
+			}
+			return result[i].Username < result[j].Username
+		})
+
+		if len(result) > limit {
+			result = result[:limit]
+		}
+
+		c.JSON(http.StatusOK, gin.H{"suggestions": result})
+	}
+}
@@ -0,0 +1,86 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shjung-dev/ChatApplication/backend/config"
+	"github.com/shjung-dev/ChatApplication/backend/helpers"
+	"github.com/shjung-dev/ChatApplication/backend/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// GetThread returns a thread root and one page of its replies, oldest first.
+// Replies are kept out of the main conversation history, so this is the only place to read them.
+func GetThread() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+		defer cancel()
+
+		claims, ok := c.Get("claims")
+
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+			return
+		}
+
+		userID := claims.(*helpers.Claims).UserID
+		page, limit, skip := pagination(c)
+
+		rootID, err := primitive.ObjectIDFromHex(c.Param("messageID"))
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Thread not found"})
+			return
+		}
+
+		var root models.Message
+
+		messageCollection := config.OpenCollection("message")
+		err = messageCollection.FindOne(ctx, bson.M{"_id": rootID, "expiresAt": helpers.Unexpired()}).Decode(&root)
+
+		if err != nil || root.ThreadID != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Thread not found"})
+			return
+		}
+
+		convo, err := findConversation(ctx, root.ConversationID)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Thread not found"})
+			return
+		}
+
+		isMember, err := helpers.IsMember(ctx, convo, userID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if !isMember {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Thread not found"})
+			return
+		}
+
+		replies, total, err := helpers.ThreadReplies(ctx, root.ID, skip, limit)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		messages := append([]models.Message{root}, replies...)
+		if err := helpers.AttachReactions(ctx, messages, userID); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"root":    messages[0],
+			"replies": messages[1:],
+			"page":    page,
+			"limit":   limit,
+			"total":   total,
+		})
+	}
+}
@@ -2,10 +2,11 @@ package controllers
 
 import (
 	"context"
-	"fmt"
 	"net/http"
+	"regexp"
 	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/gin-gonic/gin"
 	"github.com/go-playground/validator/v10"
@@ -14,10 +15,30 @@ import (
 	"github.com/shjung-dev/ChatApplication/backend/models"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 var validate = validator.New()
 
+// authResponse is the body returned after signing up or logging in. The tokens are only
+// ever sent at the top level, never as part of the user.
+func authResponse(message string, user models.User, accessToken string, refreshToken string) gin.H {
+	return gin.H{
+		"message":       message,
+		"user":          user.Self(),
+		"access_token":  accessToken,
+		"refresh_token": refreshToken,
+	}
+}
+
+// receiverResponse is the body SearchUser returns for a user found by username.
+func receiverResponse(message string, user models.User) gin.H {
+	return gin.H{
+		"message":  message,
+		"receiver": user.Public(),
+	}
+}
+
 func Signup() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
@@ -35,6 +56,11 @@ func Signup() gin.HandlerFunc {
 			return
 		}
 
+		if *user.Username == models.AnnounceUsername || *user.Username == models.DeletedUsername {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "username is reserved"})
+			return
+		}
+
 		userCollection := config.OpenCollection("user")
 		count, err := userCollection.CountDocuments(ctx, bson.M{"username": user.Username})
 
@@ -61,14 +87,10 @@ func Signup() gin.HandlerFunc {
 
 		if insertErr != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": insertErr.Error()})
+			return
 		}
 
-		c.JSON(http.StatusOK, gin.H{
-			"message":       "signup successful",
-			"user":          user,
-			"access_token":  accessToken,
-			"refresh_token": refreshToken,
-		})
+		c.JSON(http.StatusOK, authResponse("signup successful", user, accessToken, refreshToken))
 	}
 }
 
@@ -93,22 +115,31 @@ func Login() gin.HandlerFunc {
 			return
 		}
 
-		passwordIsValid, msg := helpers.VerifyPassword(*foundUser.Password, *user.Password)
+		passwordIsValid, _ := helpers.VerifyPassword(*foundUser.Password, *user.Password)
 
 		if !passwordIsValid {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": msg})
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid password"})
+			return
+		}
+
+		//Logging in during the grace period cancels a requested deletion
+		if foundUser.DeletionAt != nil {
+			_, err := userCollection.UpdateOne(ctx, bson.M{"user_id": foundUser.User_id}, bson.M{
+				"$unset": bson.M{"deletion_at": ""},
+			})
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			foundUser.DeletionAt = nil
+			helpers.Audit(ctx, "account_deletion_cancelled", foundUser.User_id, "")
 		}
 
 		token, refreshToken := helpers.GenerateToken(foundUser.User_id, *foundUser.Username)
 
 		helpers.UpdateAllToken(token, refreshToken, foundUser.User_id)
 		
-		c.JSON(http.StatusOK, gin.H{
-			"message":       "login successful",
-			"user":          foundUser,
-			"access_token":  token,
-			"refresh_token": refreshToken,
-		})
+		c.JSON(http.StatusOK, authResponse("login successful", foundUser, token, refreshToken))
 	}
 }
 
@@ -124,7 +155,7 @@ func SearchUser() gin.HandlerFunc {
 			return
 		}
 
-		sender := claims.(*helpers.Claims).Username
+		sender := claims.(*helpers.Claims).UserID
 
 		receiver := c.Param("receiver")
 
@@ -132,9 +163,24 @@ func SearchUser() gin.HandlerFunc {
 
 		userCollection := config.OpenCollection("user")
 
-		err := userCollection.FindOne(ctx, bson.M{"username": receiver}).Decode(&user)
+		err := userCollection.FindOne(ctx, bson.M{"username": receiver},
+			options.FindOne().SetProjection(publicUserProjection),
+		).Decode(&user)
+
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		}
+
+		//Blocked users cannot find each other
+		blocked, err := helpers.IsBlockedEitherWay(ctx, sender, user.User_id)
 
 		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if blocked {
 			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
 			return
 		}
@@ -142,39 +188,37 @@ func SearchUser() gin.HandlerFunc {
 		var request models.Request
        
 		filter := bson.M{
-			"from": sender,
-			"to":   receiver,
+			"from_id": sender,
+			"to_id":   user.User_id,
 		}
 
 		requestCollection := config.OpenCollection("request")
 
 		err = requestCollection.FindOne(ctx, filter).Decode(&request)
 
-		if err != nil {
+		//A rejection only blocks new requests until its cool-down ends
+		if err == nil && request.Status == "rejected" && request.ExpiresAt != nil && time.Now().Before(*request.ExpiresAt) {
+			response := receiverResponse("cooldown", user)
+			response["until"] = request.ExpiresAt
+			c.JSON(http.StatusOK, response)
+			return
+		}
+
+		if err != nil || request.Status == "rejected" {
 			//Request has not been sent to the receiver yet
-			fmt.Println(err.Error())
-			c.JSON(http.StatusOK, gin.H{
-				"message":  "available",
-				"receiver": user,
-			})
+			c.JSON(http.StatusOK, receiverResponse("available", user))
 			return
 		}
 
 		//Request has already been sent and it is not accepted yet
 		if request.Status == "pending" {
-			c.JSON(http.StatusOK, gin.H{
-				"message":  "pending",
-				"receiver": user,
-			})
+			c.JSON(http.StatusOK, receiverResponse("pending", user))
 			return
 		}
 
 		//Request has already been sent and it is accepted already
 		if request.Status == "accepted" {
-			c.JSON(http.StatusOK, gin.H{
-				"message":  "accepted",
-				"receiver": user,
-			})
+			c.JSON(http.StatusOK, receiverResponse("accepted", user))
 			return
 		}
 	}
@@ -234,3 +278,99 @@ func RefreshTokenHandler() gin.HandlerFunc {
 		})
 	}
 }
+
+const maxUserSearchLength = 64
+
+func SearchUsers() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+		defer cancel()
+
+		claims, ok := c.Get("claims")
+
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+
+		searcher := claims.(*helpers.Claims).UserID
+
+		//Fuzzy patterns grow with every character, so keep them short
+		q := strings.TrimSpace(c.Query("q"))
+		if q == "" || utf8.RuneCountInString(q) > maxUserSearchLength {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "q must be between 1 and 64 characters"})
+			return
+		}
+
+		var pattern string
+		switch c.DefaultQuery("mode", "prefix") {
+		case "prefix":
+			pattern = "^" + regexp.QuoteMeta(q)
+		case "fuzzy":
+			//Letters must appear in order but anything may sit between them -> "jdo" matches "john_doe"
+			parts := make([]string, 0, len(q))
+			for _, r := range q {
+				parts = append(parts, regexp.QuoteMeta(string(r)))
+			}
+			pattern = strings.Join(parts, ".*")
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{"error": "mode must be prefix or fuzzy"})
+			return
+		}
+
+		page, limit, skip := pagination(c)
+
+		blocked, err := helpers.BlockedUserIDs(ctx, searcher)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		regex := primitive.Regex{Pattern: pattern, Options: "i"}
+		filter := bson.M{
+			"user_id": bson.M{"$nin": append(blocked, searcher)},
+			"$or": bson.A{
+				bson.M{"username": regex},
+				bson.M{"display_name": regex},
+			},
+		}
+
+		userCollection := config.OpenCollection("user")
+
+		total, err := userCollection.CountDocuments(ctx, filter)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		cursor, err := userCollection.Find(ctx, filter, options.Find().
+			SetProjection(publicUserProjection).
+			SetSort(bson.D{{Key: "username", Value: 1}}).
+			SetSkip(int64(skip)).
+			SetLimit(int64(limit)),
+		)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		var users []models.User
+
+		if err := cursor.All(ctx, &users); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		results := make([]models.PublicUser, 0, len(users))
+		for _, u := range users {
+			results = append(results, u.Public())
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"users": results,
+			"page":  page,
+			"limit": limit,
+			"total": total,
+		})
+	}
+}
@@ -0,0 +1,85 @@
+package controllers
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/shjung-dev/ChatApplication/backend/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func str(s string) *string {
+	return &s
+}
+
+func storedUser() models.User {
+	id := primitive.NewObjectID()
+	return models.User{
+		ID:            id,
+		Username:      str("alice"),
+		Password:      str("$2a$14$hash"),
+		Token:         str("stored-access-token"),
+		Refresh_token: str("stored-refresh-token"),
+		Created_at:    time.Now(),
+		Updated_at:    time.Now(),
+		User_id:       id.Hex(),
+	}
+}
+
+// decode round-trips a response body the way a client would see it.
+func decode(t *testing.T, body interface{}) map[string]interface{} {
+	t.Helper()
+
+	data, err := json.Marshal(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var fields map[string]interface{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		t.Fatal(err)
+	}
+	return fields
+}
+
+func assertAbsent(t *testing.T, name string, fields map[string]interface{}, keys ...string) {
+	t.Helper()
+
+	for _, key := range keys {
+		if _, found := fields[key]; found {
+			t.Errorf("%s contains %q", name, key)
+		}
+	}
+}
+
+func TestAuthResponseHidesCredentials(t *testing.T) {
+	for _, message := range []string{"signup successful", "login successful"} {
+		body := decode(t, authResponse(message, storedUser(), "new-access-token", "new-refresh-token"))
+
+		//The freshly issued tokens are the only ones that are sent, and only at the top level
+		assertAbsent(t, message, body, "password", "token")
+		if body["access_token"] != "new-access-token" || body["refresh_token"] != "new-refresh-token" {
+			t.Errorf("%s: unexpected tokens %v, %v", message, body["access_token"], body["refresh_token"])
+		}
+
+		user, ok := body["user"].(map[string]interface{})
+		if !ok {
+			t.Fatalf("%s: user missing", message)
+		}
+		assertAbsent(t, message+" user", user, "password", "token", "refresh_token", "access_token")
+	}
+}
+
+func TestReceiverResponseHidesCredentials(t *testing.T) {
+	for _, message := range []string{"available", "pending", "accepted", "cooldown"} {
+		body := decode(t, receiverResponse(message, storedUser()))
+		assertAbsent(t, message, body, "password", "token", "refresh_token")
+
+		receiver, ok := body["receiver"].(map[string]interface{})
+		if !ok {
+			t.Fatalf("%s: receiver missing", message)
+		}
+		assertAbsent(t, message+" receiver", receiver, "password", "token", "refresh_token", "created_at", "deletion_at")
+	}
+}
@@ -0,0 +1,226 @@
+package helpers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/shjung-dev/ChatApplication/backend/config"
+	"github.com/shjung-dev/ChatApplication/backend/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var accountDeletionGrace time.Duration
+
+// SetAccountDeletionGrace sets how long a requested deletion waits before the
+// account is erased. Logging back in during that time cancels the deletion.
+func SetAccountDeletionGrace(grace time.Duration) {
+	accountDeletionGrace = grace
+}
+
+func GetAccountDeletionGrace() time.Duration {
+	return accountDeletionGrace
+}
+
+func Audit(ctx context.Context, action string, userID string, details string) {
+	auditCollection := config.OpenCollection("audit")
+	_, err := auditCollection.InsertOne(ctx, models.AuditEntry{
+		ID:        primitive.NewObjectID(),
+		Action:    action,
+		UserID:    userID,
+		Details:   details,
+		CreatedAt: time.Now(),
+	})
+	if err != nil {
+		log.Println("Failed to write audit entry:", err)
+	}
+}
+
+// RevokeTokens invalidates the user's access and refresh tokens immediately.
+func RevokeTokens(ctx context.Context, userID string) error {
+	userCollection := config.OpenCollection("user")
+	_, err := userCollection.UpdateOne(ctx, bson.M{"user_id": userID}, bson.M{
+		"$set": bson.M{
+			"token":         "",
+			"refresh_token": "",
+			"updated_at":    time.Now(),
+		},
+	})
+	return err
+}
+
+// EraseAccount removes the user and everything that only belongs to them.
+// Messages in shared conversations are kept for the other participants but no longer carry the user's name.
+func EraseAccount(ctx context.Context, user models.User) error {
+	ref := user.Ref()
+
+	if _, err := config.OpenCollection("friend").DeleteMany(ctx, bson.M{
+		"$or": bson.A{
+			bson.M{"user_id": ref.ID},
+			bson.M{"friend_user_id": ref.ID},
+		},
+	}); err != nil {
+		return err
+	}
+
+	if _, err := config.OpenCollection("request").DeleteMany(ctx, bson.M{
+		"$or": bson.A{
+			bson.M{"from_id": ref.ID},
+			bson.M{"to_id": ref.ID},
+		},
+	}); err != nil {
+		return err
+	}
+
+	if _, err := config.OpenCollection("request_log").DeleteMany(ctx, bson.M{"from_id": ref.ID}); err != nil {
+		return err
+	}
+
+	if _, err := config.OpenCollection("block").DeleteMany(ctx, bson.M{
+		"$or": bson.A{
+			bson.M{"blocker_id": ref.ID},
+			bson.M{"blocked_id": ref.ID},
+		},
+	}); err != nil {
+		return err
+	}
+
+	if _, err := config.OpenCollection("reaction").DeleteMany(ctx, bson.M{"user_id": ref.ID}); err != nil {
+		return err
+	}
+
+	if _, err := config.OpenCollection("scheduled").DeleteMany(ctx, bson.M{"user_id": ref.ID}); err != nil {
+		return err
+	}
+
+	//Messages are kept anonymized, but the files in them are the user's own data
+	if err := DeleteUserAttachments(ctx, ref.ID); err != nil {
+		return err
+	}
+
+	if _, err := config.OpenCollection("message").UpdateMany(ctx,
+		bson.M{"senderID": ref.ID},
+		bson.M{"$set": bson.M{"senderUserName": models.DeletedUsername}},
+	); err != nil {
+		return err
+	}
+
+	if _, err := config.OpenCollection("message").UpdateMany(ctx,
+		bson.M{"replyTo.senderID": ref.ID},
+		bson.M{"$set": bson.M{"replyTo.senderUserName": models.DeletedUsername}},
+	); err != nil {
+		return err
+	}
+
+	if _, err := config.OpenCollection("message").UpdateMany(ctx,
+		bson.M{"forwardedFrom.senderID": ref.ID},
+		bson.M{"$set": bson.M{"forwardedFrom.senderUserName": models.DeletedUsername}},
+	); err != nil {
+		return err
+	}
+
+	if err := renameMentions(ctx, ref.ID, models.DeletedUsername); err != nil {
+		return err
+	}
+
+	if _, err := config.OpenCollection("message").UpdateMany(ctx,
+		bson.M{"thread.participantIDs": ref.ID},
+		bson.M{"$pull": bson.M{"thread.participantIDs": ref.ID}},
+	); err != nil {
+		return err
+	}
+
+	convoCollection := config.OpenCollection("conversation")
+
+	//Leave every group. In 1-to-1 chats only the name is replaced so the other side keeps the chat
+	if _, err := convoCollection.UpdateMany(ctx,
+		bson.M{"participantIDs": ref.ID, "conversationName": bson.M{"$nin": bson.A{nil, ""}}},
+		bson.M{"$pull": bson.M{
+			"participantIDs": ref.ID,
+			"participants":   ref.Username,
+			"adminIDs":       ref.ID,
+			"admins":         ref.Username,
+		}},
+	); err != nil {
+		return err
+	}
+
+	if _, err := convoCollection.UpdateMany(ctx,
+		bson.M{"participantIDs": ref.ID},
+		bson.M{"$set": bson.M{"participants.$[name]": models.DeletedUsername}},
+		options.Update().SetArrayFilters(options.ArrayFilters{
+			Filters: []interface{}{bson.M{"name": ref.Username}},
+		}),
+	); err != nil {
+		return err
+	}
+
+	channelIDs, err := ChannelIDsForMember(ctx, ref.ID)
+	if err != nil {
+		return err
+	}
+	for _, id := range channelIDs {
+		convo := models.Conversation{ConversationID: id, Type: models.ConversationChannel}
+		if _, err := RemoveMember(ctx, convo, ref); err != nil && !errors.Is(err, ErrNotMember) {
+			return err
+		}
+	}
+
+	if _, err := config.OpenCollection("invite").UpdateMany(ctx,
+		bson.M{"createdByID": ref.ID},
+		bson.M{"$set": bson.M{"revoked": true}},
+	); err != nil {
+		return err
+	}
+
+	if err := DeleteExports(ctx, ref.ID); err != nil {
+		return err
+	}
+
+	if _, err := config.OpenCollection("user").DeleteOne(ctx, bson.M{"user_id": ref.ID}); err != nil {
+		return err
+	}
+
+	Audit(ctx, "account_deleted", ref.ID, "")
+	return nil
+}
+
+// StartAccountDeletionSweeper erases accounts whose grace period has ended.
+func StartAccountDeletionSweeper(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			sweepDeletedAccounts()
+		}
+	}()
+}
+
+func sweepDeletedAccounts() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	userCollection := config.OpenCollection("user")
+	cursor, err := userCollection.Find(ctx, bson.M{"deletion_at": bson.M{"$lte": time.Now()}})
+	if err != nil {
+		log.Println("Failed to fetch accounts due for deletion:", err)
+		return
+	}
+
+	var users []models.User
+
+	if err := cursor.All(ctx, &users); err != nil {
+		log.Println(err.Error())
+		return
+	}
+
+	for _, u := range users {
+		if err := EraseAccount(ctx, u); err != nil {
+			log.Println("Failed to erase account", u.User_id+":", err)
+		}
+	}
+}
@@ -0,0 +1,459 @@
+package helpers
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shjung-dev/ChatApplication/backend/config"
+	"github.com/shjung-dev/ChatApplication/backend/models"
+	"github.com/shjung-dev/ChatApplication/backend/storage"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type AttachmentPolicy struct {
+	MaxSize      int64    //Bytes
+	AllowedTypes []string //MIME types sniffed from the contents, not taken from the client
+}
+
+var attachmentPolicy = AttachmentPolicy{
+	MaxSize: 25 << 20,
+	AllowedTypes: []string{
+		"image/jpeg",
+		"image/png",
+		"image/gif", //WebP is left out until the media pipeline can strip its EXIF and XMP chunks
+		"application/pdf",
+		"application/zip",
+		"text/plain",
+	},
+}
+
+var blobStore storage.BlobStore
+
+func SetAttachmentPolicy(policy AttachmentPolicy) {
+	attachmentPolicy = policy
+}
+
+func GetAttachmentPolicy() AttachmentPolicy {
+	return attachmentPolicy
+}
+
+func SetBlobStore(store storage.BlobStore) {
+	blobStore = store
+}
+
+func GetBlobStore() storage.BlobStore {
+	return blobStore
+}
+
+var (
+	ErrAttachmentTooLarge = errors.New("file is too large")
+	ErrAttachmentType     = errors.New("file type is not allowed")
+)
+
+// unclaimedAttachmentTTL is how long an upload may wait for a message before it is deleted.
+const unclaimedAttachmentTTL = 24 * time.Hour
+
+// StoreAttachment validates and stores an upload into convoID and records its metadata.
+func StoreAttachment(ctx context.Context, convoID string, uploaderID string, name string, size int64, body io.Reader) (models.Attachment, error) {
+	var attachment models.Attachment
+
+	if size > attachmentPolicy.MaxSize {
+		return attachment, ErrAttachmentTooLarge
+	}
+
+	//Sniff the type from the first bytes so a renamed file can't sneak past the allow list
+	head := make([]byte, 512)
+	n, err := io.ReadFull(body, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return attachment, err
+	}
+	head = head[:n]
+
+	mimeType, _, err := mime.ParseMediaType(http.DetectContentType(head))
+	if err != nil || !allowedAttachmentType(mimeType) {
+		return attachment, ErrAttachmentType
+	}
+
+	attachmentID := uuid.NewString()
+	hash := sha256.New()
+	content := io.TeeReader(io.MultiReader(bytes.NewReader(head), body), hash)
+
+	attachment = models.Attachment{
+		ID:             primitive.NewObjectID(),
+		AttachmentID:   attachmentID,
+		ConversationID: convoID,
+		UploaderID:     uploaderID,
+		Name:           cleanAttachmentName(name),
+		MimeType:       mimeType,
+		Size:           size,
+		StorageKey:     "attachments/" + convoID + "/" + attachmentID,
+		CreatedAt:      time.Now(),
+	}
+	if IsProcessableImage(mimeType) {
+		attachment.Status = models.AttachmentProcessing
+	}
+
+	if err := blobStore.Put(ctx, attachment.StorageKey, content, size, mimeType); err != nil {
+		return attachment, err
+	}
+	attachment.Checksum = hex.EncodeToString(hash.Sum(nil))
+
+	attachmentCollection := config.OpenCollection("attachment")
+	if _, err := attachmentCollection.InsertOne(ctx, attachment); err != nil {
+		blobStore.Delete(ctx, attachment.StorageKey)
+		return attachment, err
+	}
+	return attachment, nil
+}
+
+func allowedAttachmentType(mimeType string) bool {
+	for _, t := range attachmentPolicy.AllowedTypes {
+		if t == mimeType {
+			return true
+		}
+	}
+	return false
+}
+
+func cleanAttachmentName(name string) string {
+	name = path.Base(strings.ReplaceAll(name, "\\", "/"))
+	if name == "." || name == "/" || name == "" {
+		return "file"
+	}
+	if len(name) > 255 {
+		name = name[:255]
+	}
+	return name
+}
+
+// ClaimAttachments attaches the caller's unclaimed uploads in convoID to messageID.
+// Unknown IDs, other people's uploads and uploads already sent are ignored.
+func ClaimAttachments(ctx context.Context, attachmentIDs []string, convoID string, uploaderID string, messageID primitive.ObjectID) ([]models.Attachment, error) {
+	if len(attachmentIDs) == 0 {
+		return nil, nil
+	}
+
+	filter := bson.M{
+		"attachment_id":  bson.M{"$in": attachmentIDs},
+		"conversationID": convoID,
+		"uploader_id":    uploaderID,
+		"message_id":     bson.M{"$exists": false},
+	}
+
+	attachmentCollection := config.OpenCollection("attachment")
+	if _, err := attachmentCollection.UpdateMany(ctx, filter, bson.M{"$set": bson.M{"message_id": messageID}}); err != nil {
+		return nil, err
+	}
+
+	var attachments []models.Attachment
+
+	err := findAll(ctx, "attachment", bson.M{"message_id": messageID}, &attachments)
+	return attachments, err
+}
+
+// CopyAttachments stores a copy of each attachment in convoID, owned by uploaderID and
+// already claimed by messageID, so a forwarded message doesn't share files with its original.
+func CopyAttachments(ctx context.Context, attachments []models.Attachment, convoID string, uploaderID string, messageID primitive.ObjectID) ([]models.Attachment, error) {
+	copies := make([]models.Attachment, 0, len(attachments))
+
+	for _, a := range attachments {
+		copied := a
+		copied.ID = primitive.NewObjectID()
+		copied.AttachmentID = uuid.NewString()
+		copied.ConversationID = convoID
+		copied.UploaderID = uploaderID
+		copied.MessageID = &messageID
+		copied.StorageKey = "attachments/" + convoID + "/" + copied.AttachmentID
+		copied.CreatedAt = time.Now()
+
+		if err := copyBlob(ctx, a.StorageKey, copied.StorageKey, a.Size, a.MimeType); err != nil {
+			deleteAttachmentBlobs(ctx, copies)
+			return nil, err
+		}
+
+		if a.ThumbnailKey != "" {
+			copied.ThumbnailKey = copied.StorageKey + "-thumb"
+			if err := copyBlob(ctx, a.ThumbnailKey, copied.ThumbnailKey, -1, a.ThumbnailType); err != nil {
+				deleteAttachmentBlobs(ctx, append(copies, copied))
+				return nil, err
+			}
+		}
+
+		copies = append(copies, copied)
+	}
+
+	if len(copies) == 0 {
+		return nil, nil
+	}
+
+	documents := make([]interface{}, 0, len(copies))
+	for _, a := range copies {
+		documents = append(documents, a)
+	}
+
+	if _, err := config.OpenCollection("attachment").InsertMany(ctx, documents); err != nil {
+		deleteAttachmentBlobs(ctx, copies)
+		return nil, err
+	}
+	return copies, nil
+}
+
+// copyBlob copies the blob at src to dst. A negative size reads the blob into memory
+// first to learn it, which is only meant for small blobs like thumbnails.
+func copyBlob(ctx context.Context, src string, dst string, size int64, contentType string) error {
+	body, err := blobStore.Get(ctx, src)
+	if err != nil {
+		return err
+	}
+
+	defer body.Close()
+
+	if size >= 0 {
+		return blobStore.Put(ctx, dst, body, size, contentType)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(body, attachmentPolicy.MaxSize+1))
+	if err != nil {
+		return err
+	}
+	return blobStore.Put(ctx, dst, bytes.NewReader(data), int64(len(data)), contentType)
+}
+
+// deleteAttachmentBlobs cleans up the files of attachments that couldn't be recorded.
+func deleteAttachmentBlobs(ctx context.Context, attachments []models.Attachment) {
+	for _, a := range attachments {
+		blobStore.Delete(ctx, a.StorageKey)
+		if a.ThumbnailKey != "" {
+			blobStore.Delete(ctx, a.ThumbnailKey)
+		}
+	}
+}
+
+// ReleaseAttachments undoes ClaimAttachments for a message that couldn't be stored, so the
+// uploads can be sent again or collected by the unclaimed-upload sweeper.
+func ReleaseAttachments(ctx context.Context, messageID primitive.ObjectID) error {
+	attachmentCollection := config.OpenCollection("attachment")
+
+	//Only images carry a status, and they are processed once their message is stored
+	if _, err := attachmentCollection.UpdateMany(ctx,
+		bson.M{"message_id": messageID, "status": bson.M{"$exists": true}},
+		bson.M{"$set": bson.M{"status": models.AttachmentProcessing}},
+	); err != nil {
+		return err
+	}
+
+	_, err := attachmentCollection.UpdateMany(ctx,
+		bson.M{"message_id": messageID},
+		bson.M{"$unset": bson.M{"message_id": ""}},
+	)
+	return err
+}
+
+// ProcessAttachment strips the metadata from a sent image, stores a thumbnail next
+// to it and records the result on both the attachment and its message.
+func ProcessAttachment(ctx context.Context, attachment models.Attachment) (models.Attachment, error) {
+	result, err := processStoredImage(ctx, attachment)
+	if err != nil {
+		return FailAttachment(ctx, attachment), err
+	}
+
+	//Overwrite the original so the location never leaves the server
+	if err := blobStore.Put(ctx, attachment.StorageKey, bytes.NewReader(result.Data), int64(len(result.Data)), attachment.MimeType); err != nil {
+		return attachment, err
+	}
+
+	thumbnailKey := attachment.StorageKey + "-thumb"
+	if err := blobStore.Put(ctx, thumbnailKey, bytes.NewReader(result.Thumbnail), int64(len(result.Thumbnail)), result.ThumbnailType); err != nil {
+		return attachment, err
+	}
+
+	sum := sha256.Sum256(result.Data)
+
+	attachment.Status = models.AttachmentReady
+	attachment.Size = int64(len(result.Data))
+	attachment.Checksum = hex.EncodeToString(sum[:])
+	attachment.Width = result.Width
+	attachment.Height = result.Height
+	attachment.ThumbWidth = result.ThumbWidth
+	attachment.ThumbHeight = result.ThumbHeight
+	attachment.ThumbnailKey = thumbnailKey
+	attachment.ThumbnailType = result.ThumbnailType
+
+	err = updateAttachment(ctx, attachment, bson.M{
+		"status":         attachment.Status,
+		"size":           attachment.Size,
+		"checksum":       attachment.Checksum,
+		"width":          attachment.Width,
+		"height":         attachment.Height,
+		"thumb_width":    attachment.ThumbWidth,
+		"thumb_height":   attachment.ThumbHeight,
+		"thumbnail_key":  attachment.ThumbnailKey,
+		"thumbnail_type": attachment.ThumbnailType,
+	})
+	return attachment, err
+}
+
+// FailAttachment records that an image couldn't be processed, which keeps it hidden for good.
+func FailAttachment(ctx context.Context, attachment models.Attachment) models.Attachment {
+	attachment.Status = models.AttachmentFailed
+	if err := updateAttachment(ctx, attachment, bson.M{"status": attachment.Status}); err != nil {
+		log.Println("Failed to mark attachment", attachment.AttachmentID, "as failed:", err)
+	}
+	return attachment
+}
+
+func processStoredImage(ctx context.Context, attachment models.Attachment) (ProcessedImage, error) {
+	body, err := blobStore.Get(ctx, attachment.StorageKey)
+	if err != nil {
+		return ProcessedImage{}, err
+	}
+
+	defer body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(body, attachmentPolicy.MaxSize+1))
+	if err != nil {
+		return ProcessedImage{}, err
+	}
+	return ProcessImage(data, attachment.MimeType)
+}
+
+// updateAttachment applies set to the attachment record and to the copy embedded in its message.
+func updateAttachment(ctx context.Context, attachment models.Attachment, set bson.M) error {
+	attachmentCollection := config.OpenCollection("attachment")
+	if _, err := attachmentCollection.UpdateOne(ctx, bson.M{"attachment_id": attachment.AttachmentID}, bson.M{"$set": set}); err != nil {
+		return err
+	}
+
+	if attachment.MessageID == nil {
+		return nil
+	}
+
+	embedded := bson.M{}
+	for field, value := range set {
+		embedded["attachments.$[a]."+field] = value
+	}
+
+	messageCollection := config.OpenCollection("message")
+	_, err := messageCollection.UpdateOne(ctx,
+		bson.M{"_id": *attachment.MessageID},
+		bson.M{"$set": embedded},
+		options.Update().SetArrayFilters(options.ArrayFilters{
+			Filters: []interface{}{bson.M{"a.attachment_id": attachment.AttachmentID}},
+		}),
+	)
+	return err
+}
+
+// PendingImageAttachments returns sent images whose processing never finished,
+// e.g. because the server restarted while they were queued.
+func PendingImageAttachments(ctx context.Context) ([]models.Attachment, error) {
+	var attachments []models.Attachment
+
+	err := findAll(ctx, "attachment", bson.M{
+		"status":     models.AttachmentProcessing,
+		"message_id": bson.M{"$exists": true},
+	}, &attachments)
+	return attachments, err
+}
+
+// DeleteMessageAttachments removes the files sent with messageIDs, thumbnails included.
+func DeleteMessageAttachments(ctx context.Context, messageIDs []primitive.ObjectID) error {
+	return deleteAttachments(ctx, bson.M{"message_id": bson.M{"$in": messageIDs}})
+}
+
+// DeleteUserAttachments removes every file the user uploaded, and takes them off the
+// messages they were sent with so nobody is shown a file that is gone.
+func DeleteUserAttachments(ctx context.Context, userID string) error {
+	if err := deleteAttachments(ctx, bson.M{"uploader_id": userID}); err != nil {
+		return err
+	}
+
+	_, err := config.OpenCollection("message").UpdateMany(ctx,
+		bson.M{"attachments.uploader_id": userID},
+		bson.M{"$pull": bson.M{"attachments": bson.M{"uploader_id": userID}}},
+	)
+	return err
+}
+
+// deleteAttachments removes the blobs and records of the attachments matching filter.
+func deleteAttachments(ctx context.Context, filter bson.M) error {
+	var attachments []models.Attachment
+
+	if err := findAll(ctx, "attachment", filter, &attachments); err != nil {
+		return err
+	}
+
+	for _, a := range attachments {
+		if err := blobStore.Delete(ctx, a.StorageKey); err != nil {
+			return err
+		}
+		if a.ThumbnailKey != "" {
+			if err := blobStore.Delete(ctx, a.ThumbnailKey); err != nil {
+				return err
+			}
+		}
+	}
+
+	_, err := config.OpenCollection("attachment").DeleteMany(ctx, filter)
+	return err
+}
+
+// StartAttachmentSweeper deletes uploads that were never sent with a message.
+func StartAttachmentSweeper(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			sweepUnclaimedAttachments()
+		}
+	}()
+}
+
+func sweepUnclaimedAttachments() {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	//Uploads waiting on a scheduled message aren't abandoned
+	scheduled, err := scheduledAttachmentIDs(ctx)
+	if err != nil {
+		log.Println("Failed to fetch scheduled attachments:", err)
+		return
+	}
+
+	var attachments []models.Attachment
+
+	err = findAll(ctx, "attachment", bson.M{
+		"message_id":    bson.M{"$exists": false},
+		"attachment_id": bson.M{"$nin": scheduled},
+		"created_at":    bson.M{"$lte": time.Now().Add(-unclaimedAttachmentTTL)},
+	}, &attachments)
+	if err != nil {
+		log.Println("Failed to fetch unclaimed attachments:", err)
+		return
+	}
+
+	attachmentCollection := config.OpenCollection("attachment")
+	for _, a := range attachments {
+		if err := blobStore.Delete(ctx, a.StorageKey); err != nil {
+			log.Println("Failed to delete attachment", a.AttachmentID+":", err)
+			continue
+		}
+		if _, err := attachmentCollection.DeleteOne(ctx, bson.M{"attachment_id": a.AttachmentID}); err != nil {
+			log.Println("Failed to delete attachment", a.AttachmentID+":", err)
+		}
+	}
+}
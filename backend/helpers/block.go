@@ -0,0 +1,69 @@
+package helpers
+
+import (
+	"context"
+
+	"github.com/shjung-dev/ChatApplication/backend/config"
+	"github.com/shjung-dev/ChatApplication/backend/models"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func HasBlocked(ctx context.Context, blockerID string, blockedID string) (bool, error) {
+	blockCollection := config.OpenCollection("block")
+	count, err := blockCollection.CountDocuments(ctx, bson.M{
+		"blocker_id": blockerID,
+		"blocked_id": blockedID,
+	})
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// IsBlockedEitherWay reports whether a has blocked b or b has blocked a.
+func IsBlockedEitherWay(ctx context.Context, a string, b string) (bool, error) {
+	blockCollection := config.OpenCollection("block")
+	count, err := blockCollection.CountDocuments(ctx, bson.M{
+		"$or": bson.A{
+			bson.M{"blocker_id": a, "blocked_id": b},
+			bson.M{"blocker_id": b, "blocked_id": a},
+		},
+	})
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// BlockedUserIDs returns everyone userID has blocked or been blocked by.
+// Neither side should be able to see or contact the other.
+func BlockedUserIDs(ctx context.Context, userID string) ([]string, error) {
+	blockCollection := config.OpenCollection("block")
+	cursor, err := blockCollection.Find(ctx, bson.M{
+		"$or": bson.A{
+			bson.M{"blocker_id": userID},
+			bson.M{"blocked_id": userID},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	defer cursor.Close(ctx)
+
+	var blocks []models.Block
+
+	if err := cursor.All(ctx, &blocks); err != nil {
+		return nil, err
+	}
+
+	result := make([]string, 0, len(blocks))
+	for _, b := range blocks {
+		if b.BlockerID == userID {
+			result = append(result, b.BlockedID)
+		} else {
+			result = append(result, b.BlockerID)
+		}
+	}
+	return result, nil
+}
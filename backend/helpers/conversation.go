@@ -0,0 +1,238 @@
+package helpers
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/shjung-dev/ChatApplication/backend/config"
+	"github.com/shjung-dev/ChatApplication/backend/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var ErrNotMember = errors.New("user is not a member of this conversation")
+
+//Channels keep their members in the member collection while 1-to-1 and group chats
+//keep them inline in ParticipantIDs. These helpers hide that difference from callers.
+
+func IsMember(ctx context.Context, convo models.Conversation, userID string) (bool, error) {
+	if !convo.IsChannel() {
+		return convo.IsParticipant(userID), nil
+	}
+
+	memberCollection := config.OpenCollection("member")
+	count, err := memberCollection.CountDocuments(ctx, bson.M{
+		"conversationID": convo.ConversationID,
+		"user_id":        userID,
+	})
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+func MemberCount(convo models.Conversation) int {
+	if convo.IsChannel() {
+		return convo.MemberCount
+	}
+	return len(convo.ParticipantIDs)
+}
+
+// AddMember adds user to convo and returns the updated conversation.
+// Adding an existing member is a no-op.
+func AddMember(ctx context.Context, convo models.Conversation, user models.UserRef) (models.Conversation, error) {
+	convoCollection := config.OpenCollection("conversation")
+
+	if !convo.IsChannel() {
+		if convo.IsParticipant(user.ID) {
+			return convo, nil
+		}
+		err := convoCollection.FindOneAndUpdate(ctx,
+			bson.M{"conversationID": convo.ConversationID, "participantIDs": bson.M{"$ne": user.ID}},
+			bson.M{"$push": bson.M{"participantIDs": user.ID, "participants": user.Username}},
+			options.FindOneAndUpdate().SetReturnDocument(options.After),
+		).Decode(&convo)
+		return convo, err
+	}
+
+	memberCollection := config.OpenCollection("member")
+	result, err := memberCollection.UpdateOne(ctx,
+		bson.M{"conversationID": convo.ConversationID, "user_id": user.ID},
+		bson.M{"$setOnInsert": models.Member{
+			ID:             primitive.NewObjectID(),
+			ConversationID: convo.ConversationID,
+			UserID:         user.ID,
+			Username:       user.Username,
+			JoinedAt:       time.Now(),
+		}},
+		options.Update().SetUpsert(true),
+	)
+	if err != nil {
+		return convo, err
+	}
+
+	if result.UpsertedCount == 0 {
+		return convo, nil
+	}
+
+	err = convoCollection.FindOneAndUpdate(ctx,
+		bson.M{"conversationID": convo.ConversationID},
+		bson.M{"$inc": bson.M{"memberCount": 1}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&convo)
+	return convo, err
+}
+
+// RemoveMember removes user (including any admin rights) from convo and returns the updated conversation.
+// It returns ErrNotMember if the user wasn't in convo.
+func RemoveMember(ctx context.Context, convo models.Conversation, user models.UserRef) (models.Conversation, error) {
+	if !convo.IsChannel() && !convo.IsParticipant(user.ID) {
+		return convo, ErrNotMember
+	}
+
+	convoCollection := config.OpenCollection("conversation")
+	filter := bson.M{"conversationID": convo.ConversationID}
+
+	update := bson.M{
+		"$pull": bson.M{
+			"participantIDs": user.ID,
+			"participants":   user.Username,
+			"adminIDs":       user.ID,
+			"admins":         user.Username,
+		},
+	}
+
+	if convo.IsChannel() {
+		memberCollection := config.OpenCollection("member")
+		result, err := memberCollection.DeleteOne(ctx, bson.M{
+			"conversationID": convo.ConversationID,
+			"user_id":        user.ID,
+		})
+		if err != nil {
+			return convo, err
+		}
+		if result.DeletedCount == 0 {
+			return convo, ErrNotMember
+		}
+		update["$inc"] = bson.M{"memberCount": -1}
+	}
+
+	err := convoCollection.FindOneAndUpdate(ctx, filter, update,
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&convo)
+	return convo, err
+}
+
+// ChannelIDsForMember returns the IDs of every channel the user belongs to.
+func ChannelIDsForMember(ctx context.Context, userID string) ([]string, error) {
+	memberCollection := config.OpenCollection("member")
+	cursor, err := memberCollection.Find(ctx, bson.M{"user_id": userID},
+		options.Find().SetProjection(bson.M{"conversationID": 1}),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	defer cursor.Close(ctx)
+
+	var members []models.Member
+
+	if err := cursor.All(ctx, &members); err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, 0, len(members))
+	for _, m := range members {
+		ids = append(ids, m.ConversationID)
+	}
+	return ids, nil
+}
+
+// ConversationIDsForUser returns the IDs of every conversation the user can read, channels included.
+func ConversationIDsForUser(ctx context.Context, userID string) ([]string, error) {
+	ids, err := ChannelIDsForMember(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	convoCollection := config.OpenCollection("conversation")
+	cursor, err := convoCollection.Find(ctx, bson.M{"participantIDs": userID},
+		options.Find().SetProjection(bson.M{"conversationID": 1}),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	defer cursor.Close(ctx)
+
+	var convos []models.Conversation
+
+	if err := cursor.All(ctx, &convos); err != nil {
+		return nil, err
+	}
+
+	for _, c := range convos {
+		ids = append(ids, c.ConversationID)
+	}
+	return ids, nil
+}
+
+// FilterChannelMembers filters userIDs down to the ones that belong to the channel.
+func FilterChannelMembers(ctx context.Context, convoID string, userIDs []string) ([]string, error) {
+	memberCollection := config.OpenCollection("member")
+	cursor, err := memberCollection.Find(ctx, bson.M{
+		"conversationID": convoID,
+		"user_id":        bson.M{"$in": userIDs},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	defer cursor.Close(ctx)
+
+	var members []models.Member
+
+	if err := cursor.All(ctx, &members); err != nil {
+		return nil, err
+	}
+
+	result := make([]string, 0, len(members))
+	for _, m := range members {
+		result = append(result, m.UserID)
+	}
+	return result, nil
+}
+
+// ConversationPartners returns the IDs of everyone who shares a 1-to-1 or group chat with the user.
+// Channel audiences are left out since they can be arbitrarily large.
+func ConversationPartners(ctx context.Context, userID string) ([]string, error) {
+	convoCollection := config.OpenCollection("conversation")
+	cursor, err := convoCollection.Find(ctx, bson.M{"participantIDs": userID},
+		options.Find().SetProjection(bson.M{"participantIDs": 1}),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	defer cursor.Close(ctx)
+
+	var convos []models.Conversation
+
+	if err := cursor.All(ctx, &convos); err != nil {
+		return nil, err
+	}
+
+	seen := map[string]bool{userID: true}
+	result := []string{}
+	for _, convo := range convos {
+		for _, p := range convo.ParticipantIDs {
+			if !seen[p] {
+				seen[p] = true
+				result = append(result, p)
+			}
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,94 @@
+package helpers
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/shjung-dev/ChatApplication/backend/config"
+	"github.com/shjung-dev/ChatApplication/backend/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MessageExpiryOptions are the disappearing-message timers a conversation can pick from.
+var MessageExpiryOptions = map[string]time.Duration{
+	"off": 0,
+	"1h":  time.Hour,
+	"1d":  24 * time.Hour,
+	"1w":  7 * 24 * time.Hour,
+}
+
+var ErrInvalidExpiry = errors.New("expiry must be one of off, 1h, 1d or 1w")
+
+// expiredBatchSize limits how many messages one sweep deletes.
+const expiredBatchSize = 500
+
+// Unexpired is the "expiresAt" condition for reading messages. It keeps out expired
+// messages that the sweeper hasn't deleted yet.
+func Unexpired() bson.M {
+	return bson.M{"$not": bson.M{"$lte": time.Now()}}
+}
+
+// SetMessageExpiry sets how long new messages in convoID live for. Messages already
+// sent keep the expiry they were sent with.
+func SetMessageExpiry(ctx context.Context, convoID string, expiry string) (time.Duration, error) {
+	ttl, ok := MessageExpiryOptions[expiry]
+	if !ok {
+		return 0, ErrInvalidExpiry
+	}
+
+	update := bson.M{"$set": bson.M{"messageExpiry": int64(ttl / time.Second)}}
+	if ttl == 0 {
+		update = bson.M{"$unset": bson.M{"messageExpiry": ""}}
+	}
+
+	_, err := config.OpenCollection("conversation").UpdateOne(ctx, bson.M{"conversationID": convoID}, update)
+	return ttl, err
+}
+
+// DeleteExpiredMessages removes a batch of messages whose time is up, along with their
+// reactions, files and pins, and returns the deleted message IDs by conversation.
+func DeleteExpiredMessages(ctx context.Context) (map[string][]primitive.ObjectID, error) {
+	var messages []models.Message
+
+	err := findAll(ctx, "message", bson.M{"expiresAt": bson.M{"$lte": time.Now()}}, &messages,
+		options.Find().
+			SetProjection(bson.M{"_id": 1, "conversationID": 1}).
+			SetSort(bson.D{{Key: "expiresAt", Value: 1}}).
+			SetLimit(expiredBatchSize),
+	)
+	if err != nil || len(messages) == 0 {
+		return nil, err
+	}
+
+	ids := make([]primitive.ObjectID, 0, len(messages))
+	byConversation := make(map[string][]primitive.ObjectID)
+	for _, m := range messages {
+		ids = append(ids, m.ID)
+		byConversation[m.ConversationID] = append(byConversation[m.ConversationID], m.ID)
+	}
+
+	//Files first: if this fails, the messages are still there to retry with
+	if err := DeleteMessageAttachments(ctx, ids); err != nil {
+		return nil, err
+	}
+
+	if _, err := config.OpenCollection("reaction").DeleteMany(ctx, bson.M{"message_id": bson.M{"$in": ids}}); err != nil {
+		return nil, err
+	}
+
+	if _, err := config.OpenCollection("conversation").UpdateMany(ctx,
+		bson.M{"pinnedMessages.messageID": bson.M{"$in": ids}},
+		bson.M{"$pull": bson.M{"pinnedMessages": bson.M{"messageID": bson.M{"$in": ids}}}},
+	); err != nil {
+		return nil, err
+	}
+
+	if _, err := config.OpenCollection("message").DeleteMany(ctx, bson.M{"_id": bson.M{"$in": ids}}); err != nil {
+		return nil, err
+	}
+
+	return byConversation, nil
+}
@@ -0,0 +1,378 @@
+package helpers
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"html/template"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shjung-dev/ChatApplication/backend/config"
+	"github.com/shjung-dev/ChatApplication/backend/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	exportDir     = filepath.Join(os.TempDir(), "chat-exports")
+	exportLinkTTL = 24 * time.Hour
+)
+
+// SetExportSettings sets where archives are written and how long their download link stays valid.
+func SetExportSettings(dir string, linkTTL time.Duration) {
+	if dir != "" {
+		exportDir = dir
+	}
+	if linkTTL > 0 {
+		exportLinkTTL = linkTTL
+	}
+}
+
+// ExportData is everything a user gets back from a data access request.
+type ExportData struct {
+	GeneratedAt   time.Time             `json:"generated_at"`
+	Profile       models.SelfUser       `json:"profile"`
+	Friends       []models.UserRef      `json:"friends"`
+	Requests      []models.Request      `json:"requests"`
+	Conversations []models.Conversation `json:"conversations"`
+	Messages      []models.Message      `json:"messages"` //Messages the user sent
+	Reactions     []models.Reaction     `json:"reactions"`
+}
+
+// StartExport queues an archive build for userID and returns the job immediately.
+// An unfinished job is reused so repeated clicks don't pile up work.
+func StartExport(ctx context.Context, userID string) (models.ExportJob, error) {
+	var job models.ExportJob
+
+	exportCollection := config.OpenCollection("export")
+	err := exportCollection.FindOne(ctx, bson.M{
+		"user_id": userID,
+		"status":  bson.M{"$in": bson.A{models.ExportPending, models.ExportRunning}},
+	}).Decode(&job)
+	if err == nil {
+		return job, nil
+	}
+
+	job = models.ExportJob{
+		ID:        primitive.NewObjectID(),
+		JobID:     uuid.NewString(),
+		UserID:    userID,
+		Status:    models.ExportPending,
+		CreatedAt: time.Now(),
+	}
+	if _, err := exportCollection.InsertOne(ctx, job); err != nil {
+		return job, err
+	}
+
+	go runExport(job)
+	return job, nil
+}
+
+func runExport(job models.ExportJob) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+
+	exportCollection := config.OpenCollection("export")
+	filter := bson.M{"job_id": job.JobID}
+
+	if _, err := exportCollection.UpdateOne(ctx, filter, bson.M{"$set": bson.M{"status": models.ExportRunning}}); err != nil {
+		log.Println("Failed to start export:", err)
+		return
+	}
+
+	path, err := buildExport(ctx, job)
+	if err != nil {
+		log.Println("Export", job.JobID, "failed:", err)
+		exportCollection.UpdateOne(ctx, filter, bson.M{"$set": bson.M{
+			"status": models.ExportFailed,
+			"error":  "Export failed, please try again",
+		}})
+		return
+	}
+
+	now := time.Now()
+	_, err = exportCollection.UpdateOne(ctx, filter, bson.M{"$set": bson.M{
+		"status":         models.ExportReady,
+		"file_path":      path,
+		"download_token": uuid.NewString(),
+		"completed_at":   now,
+		"expires_at":     now.Add(exportLinkTTL),
+	}})
+	if err != nil {
+		log.Println("Failed to finish export:", err)
+		os.Remove(path)
+		return
+	}
+
+	Audit(ctx, "data_exported", job.UserID, job.JobID)
+}
+
+func buildExport(ctx context.Context, job models.ExportJob) (string, error) {
+	data, err := collectExportData(ctx, job.UserID)
+	if err != nil {
+		return "", err
+	}
+
+	if err := os.MkdirAll(exportDir, 0o700); err != nil {
+		return "", err
+	}
+
+	path := filepath.Join(exportDir, job.JobID+".zip")
+	file, err := os.Create(path)
+	if err != nil {
+		return "", err
+	}
+
+	if err := writeExportArchive(file, data); err != nil {
+		file.Close()
+		os.Remove(path)
+		return "", err
+	}
+
+	if err := file.Close(); err != nil {
+		os.Remove(path)
+		return "", err
+	}
+	return path, nil
+}
+
+func collectExportData(ctx context.Context, userID string) (ExportData, error) {
+	data := ExportData{GeneratedAt: time.Now()}
+
+	var user models.User
+
+	if err := config.OpenCollection("user").FindOne(ctx, bson.M{"user_id": userID}).Decode(&user); err != nil {
+		return data, err
+	}
+	data.Profile = user.Self()
+
+	var friends []models.Friend
+
+	if err := findAll(ctx, "friend", bson.M{"user_id": userID}, &friends); err != nil {
+		return data, err
+	}
+	data.Friends = make([]models.UserRef, 0, len(friends))
+	for _, f := range friends {
+		ref := models.UserRef{ID: f.FriendUserID}
+		if f.FriendUsername != nil {
+			ref.Username = *f.FriendUsername
+		}
+		data.Friends = append(data.Friends, ref)
+	}
+
+	data.Requests = []models.Request{}
+	if err := findAll(ctx, "request", bson.M{
+		"$or": bson.A{
+			bson.M{"from_id": userID},
+			bson.M{"to_id": userID},
+		},
+	}, &data.Requests); err != nil {
+		return data, err
+	}
+
+	channelIDs, err := ChannelIDsForMember(ctx, userID)
+	if err != nil {
+		return data, err
+	}
+
+	data.Conversations = []models.Conversation{}
+	if err := findAll(ctx, "conversation", bson.M{
+		"$or": bson.A{
+			bson.M{"participantIDs": userID},
+			bson.M{"conversationID": bson.M{"$in": channelIDs}},
+		},
+	}, &data.Conversations); err != nil {
+		return data, err
+	}
+
+	data.Messages = []models.Message{}
+	if err := findAll(ctx, "message", bson.M{"senderID": userID}, &data.Messages,
+		options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}}),
+	); err != nil {
+		return data, err
+	}
+
+	data.Reactions = []models.Reaction{}
+	err = findAll(ctx, "reaction", bson.M{"user_id": userID}, &data.Reactions,
+		options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}}),
+	)
+	return data, err
+}
+
+func findAll(ctx context.Context, collectionName string, filter bson.M, result interface{}, opts ...*options.FindOptions) error {
+	cursor, err := config.OpenCollection(collectionName).Find(ctx, filter, opts...)
+	if err != nil {
+		return err
+	}
+
+	defer cursor.Close(ctx)
+
+	return cursor.All(ctx, result)
+}
+
+var exportPage = template.Must(template.New("export").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>Your data</title></head>
+<body>
+<h1>Your data</h1>
+<p>Generated {{.GeneratedAt.Format "2006-01-02 15:04 MST"}}</p>
+
+<h2>Profile</h2>
+<table>
+<tr><th>Username</th><td>{{.Profile.Username}}</td></tr>
+<tr><th>Display name</th><td>{{.Profile.DisplayName}}</td></tr>
+<tr><th>Bio</th><td>{{.Profile.Bio}}</td></tr>
+<tr><th>Status</th><td>{{.Profile.StatusText}}</td></tr>
+<tr><th>Avatar</th><td>{{.Profile.AvatarURL}}</td></tr>
+<tr><th>Joined</th><td>{{.Profile.CreatedAt.Format "2006-01-02"}}</td></tr>
+</table>
+
+<h2>Friends ({{len .Friends}})</h2>
+<ul>{{range .Friends}}<li>{{.Username}}</li>{{end}}</ul>
+
+<h2>Friend requests ({{len .Requests}})</h2>
+<table>
+<tr><th>From</th><th>To</th><th>Status</th><th>Sent</th></tr>
+{{range .Requests}}<tr><td>{{.From}}</td><td>{{.To}}</td><td>{{.Status}}</td><td>{{.CreatedAt.Format "2006-01-02 15:04"}}</td></tr>
+{{end}}</table>
+
+<h2>Conversations ({{len .Conversations}})</h2>
+<ul>{{range .Conversations}}<li>{{if .ConversationName}}{{.ConversationName}}{{else}}Direct chat{{end}} ({{.Kind}})</li>{{end}}</ul>
+
+<h2>Messages you sent ({{len .Messages}})</h2>
+<table>
+<tr><th>Sent</th><th>Conversation</th><th>Message</th></tr>
+{{range .Messages}}<tr><td>{{.CreatedAt.Format "2006-01-02 15:04"}}</td><td>{{.ConversationID}}</td><td>{{.Content}}</td></tr>
+{{end}}</table>
+</body>
+</html>
+`))
+
+func writeExportArchive(file *os.File, data ExportData) error {
+	archive := zip.NewWriter(file)
+
+	jsonFile, err := archive.Create("data.json")
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(jsonFile)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(data); err != nil {
+		return err
+	}
+
+	htmlFile, err := archive.Create("index.html")
+	if err != nil {
+		return err
+	}
+	if err := exportPage.Execute(htmlFile, data); err != nil {
+		return err
+	}
+
+	return archive.Close()
+}
+
+// FindExportByToken returns the ready export that token unlocks, if its link hasn't expired.
+func FindExportByToken(ctx context.Context, token string) (models.ExportJob, error) {
+	var job models.ExportJob
+
+	exportCollection := config.OpenCollection("export")
+	err := exportCollection.FindOne(ctx, bson.M{
+		"download_token": token,
+		"status":         models.ExportReady,
+		"expires_at":     bson.M{"$gt": time.Now()},
+	}).Decode(&job)
+	return job, err
+}
+
+// StartExportSweeper deletes archives whose download link has expired. Exports left
+// unfinished by a previous run are failed first so their users can start new ones.
+func StartExportSweeper(interval time.Duration) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	if err := failInterruptedExports(ctx); err != nil {
+		log.Println("Failed to recover exports:", err)
+	}
+	cancel()
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			sweepExports()
+		}
+	}()
+}
+
+// failInterruptedExports marks exports that a previous run never finished as failed,
+// since StartExport would otherwise hand the same dead job back forever.
+func failInterruptedExports(ctx context.Context) error {
+	var jobs []models.ExportJob
+
+	filter := bson.M{"status": bson.M{"$in": bson.A{models.ExportPending, models.ExportRunning}}}
+	if err := findAll(ctx, "export", filter, &jobs); err != nil {
+		return err
+	}
+
+	//Drop any half-written archive
+	for _, job := range jobs {
+		path := filepath.Join(exportDir, job.JobID+".zip")
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			log.Println("Failed to delete export", job.JobID+":", err)
+		}
+	}
+
+	_, err := config.OpenCollection("export").UpdateMany(ctx, filter, bson.M{"$set": bson.M{
+		"status": models.ExportFailed,
+		"error":  "Export was interrupted, please try again",
+	}})
+	return err
+}
+
+func sweepExports() {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	var jobs []models.ExportJob
+
+	if err := findAll(ctx, "export", bson.M{"expires_at": bson.M{"$lte": time.Now()}}, &jobs); err != nil {
+		log.Println("Failed to fetch expired exports:", err)
+		return
+	}
+
+	exportCollection := config.OpenCollection("export")
+	for _, job := range jobs {
+		if err := os.Remove(job.FilePath); err != nil && !os.IsNotExist(err) {
+			log.Println("Failed to delete export", job.JobID+":", err)
+			continue
+		}
+		if _, err := exportCollection.DeleteOne(ctx, bson.M{"job_id": job.JobID}); err != nil {
+			log.Println("Failed to delete export", job.JobID+":", err)
+		}
+	}
+}
+
+// DeleteExports removes every export the user has requested, archives included.
+func DeleteExports(ctx context.Context, userID string) error {
+	var jobs []models.ExportJob
+
+	if err := findAll(ctx, "export", bson.M{"user_id": userID}, &jobs); err != nil {
+		return err
+	}
+
+	for _, job := range jobs {
+		if job.FilePath != "" {
+			if err := os.Remove(job.FilePath); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+	}
+
+	_, err := config.OpenCollection("export").DeleteMany(ctx, bson.M{"user_id": userID})
+	return err
+}
@@ -0,0 +1,136 @@
+package helpers
+
+import (
+	"context"
+	"log"
+
+	"github.com/shjung-dev/ChatApplication/backend/config"
+	"github.com/shjung-dev/ChatApplication/backend/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+//A friendship is stored as two mirrored friend documents so that either side
+//can list their friends with a plain {"user_id": me} query.
+
+func AddFriendship(ctx context.Context, a models.UserRef, b models.UserRef) error {
+	friendCollection := config.OpenCollection("friend")
+
+	writes := []mongo.WriteModel{
+		friendUpsert(a, b),
+		friendUpsert(b, a),
+	}
+
+	_, err := friendCollection.BulkWrite(ctx, writes)
+	return err
+}
+
+func RemoveFriendship(ctx context.Context, a string, b string) (int64, error) {
+	friendCollection := config.OpenCollection("friend")
+
+	result, err := friendCollection.DeleteMany(ctx, bson.M{
+		"$or": bson.A{
+			bson.M{"user_id": a, "friend_user_id": b},
+			bson.M{"user_id": b, "friend_user_id": a},
+		},
+	})
+	if err != nil {
+		return 0, err
+	}
+	return result.DeletedCount, nil
+}
+
+func AreFriends(ctx context.Context, a string, b string) (bool, error) {
+	friendCollection := config.OpenCollection("friend")
+
+	count, err := friendCollection.CountDocuments(ctx, bson.M{
+		"$or": bson.A{
+			bson.M{"user_id": a, "friend_user_id": b},
+			bson.M{"user_id": b, "friend_user_id": a},
+		},
+	})
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// BackfillFriendships adds the missing mirror document for friendships that
+// were stored one-sided before friendships became symmetric.
+func BackfillFriendships(ctx context.Context) {
+	friendCollection := config.OpenCollection("friend")
+
+	cursor, err := friendCollection.Find(ctx, bson.M{})
+	if err != nil {
+		log.Println("Failed to fetch friends for backfill:", err)
+		return
+	}
+
+	defer cursor.Close(ctx)
+
+	var friends []models.Friend
+
+	if err := cursor.All(ctx, &friends); err != nil {
+		log.Println("Failed to decode friends for backfill:", err)
+		return
+	}
+
+	writes := make([]mongo.WriteModel, 0, len(friends))
+	for _, f := range friends {
+		if f.UserID == "" || f.FriendUserID == "" || f.Username == nil || f.FriendUsername == nil {
+			continue
+		}
+		writes = append(writes, friendUpsert(
+			models.UserRef{ID: f.FriendUserID, Username: *f.FriendUsername},
+			models.UserRef{ID: f.UserID, Username: *f.Username},
+		))
+	}
+
+	if len(writes) == 0 {
+		return
+	}
+
+	result, err := friendCollection.BulkWrite(ctx, writes)
+	if err != nil {
+		log.Println("Failed to backfill friendships:", err)
+		return
+	}
+
+	if result.UpsertedCount > 0 {
+		log.Printf("Backfilled %d one-sided friendships", result.UpsertedCount)
+	}
+}
+
+func friendUpsert(user models.UserRef, friend models.UserRef) mongo.WriteModel {
+	return mongo.NewUpdateOneModel().
+		SetFilter(bson.M{"user_id": user.ID, "friend_user_id": friend.ID}).
+		SetUpdate(bson.M{"$set": bson.M{
+			"username":       user.Username,
+			"friendusername": friend.Username,
+		}}).
+		SetUpsert(true)
+}
+
+// FriendIDs returns the user IDs of everyone userID is friends with.
+func FriendIDs(ctx context.Context, userID string) ([]string, error) {
+	friendCollection := config.OpenCollection("friend")
+
+	cursor, err := friendCollection.Find(ctx, bson.M{"user_id": userID})
+	if err != nil {
+		return nil, err
+	}
+
+	defer cursor.Close(ctx)
+
+	var friends []models.Friend
+
+	if err := cursor.All(ctx, &friends); err != nil {
+		return nil, err
+	}
+
+	result := make([]string, 0, len(friends))
+	for _, f := range friends {
+		result = append(result, f.FriendUserID)
+	}
+	return result, nil
+}
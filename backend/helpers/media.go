@@ -0,0 +1,442 @@
+package helpers
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/draw"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+)
+
+const (
+	thumbnailSize    = 320        //Longest side in pixels
+	maxImagePixels   = 50_000_000 //Refuse to decode anything larger than this
+	jpegThumbQuality = 80
+)
+
+var (
+	ErrImageTooLarge = errors.New("image dimensions are too large")
+	ErrImageEmpty    = errors.New("image has no pixels")
+)
+
+// ProcessedImage is the outcome of running an uploaded image through the media pipeline.
+type ProcessedImage struct {
+	Data          []byte //The image with location and other metadata removed
+	Width         int    //As displayed, i.e. after applying the EXIF orientation
+	Height        int
+	Thumbnail     []byte
+	ThumbnailType string
+	ThumbWidth    int
+	ThumbHeight   int
+}
+
+// IsProcessableImage reports whether the media pipeline handles mimeType.
+func IsProcessableImage(mimeType string) bool {
+	switch mimeType {
+	case "image/jpeg", "image/png", "image/gif":
+		return true
+	}
+	return false
+}
+
+// ProcessImage strips metadata from an image and renders a thumbnail of it.
+func ProcessImage(data []byte, mimeType string) (ProcessedImage, error) {
+	var result ProcessedImage
+
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return result, err
+	}
+	if cfg.Width == 0 || cfg.Height == 0 {
+		return result, ErrImageEmpty
+	}
+	if cfg.Width*cfg.Height > maxImagePixels {
+		return result, ErrImageTooLarge
+	}
+
+	orientation := 1
+	switch mimeType {
+	case "image/jpeg":
+		result.Data, orientation, err = stripJPEGMetadata(data)
+	case "image/png":
+		result.Data, err = stripPNGMetadata(data)
+	case "image/gif":
+		result.Data, err = stripGIFMetadata(data)
+	default:
+		result.Data = data
+	}
+	if err != nil {
+		return result, err
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(result.Data))
+	if err != nil {
+		return result, err
+	}
+
+	thumb := orient(resize(img, thumbnailSize), orientation)
+	result.ThumbWidth = thumb.Bounds().Dx()
+	result.ThumbHeight = thumb.Bounds().Dy()
+
+	result.Width, result.Height = cfg.Width, cfg.Height
+	if orientation >= 5 {
+		result.Width, result.Height = result.Height, result.Width
+	}
+
+	var buf bytes.Buffer
+	if mimeType == "image/jpeg" {
+		result.ThumbnailType = "image/jpeg"
+		err = jpeg.Encode(&buf, thumb, &jpeg.Options{Quality: jpegThumbQuality})
+	} else {
+		//Keep transparency for PNG and GIF
+		result.ThumbnailType = "image/png"
+		err = png.Encode(&buf, thumb)
+	}
+	result.Thumbnail = buf.Bytes()
+	return result, err
+}
+
+// resize scales img down so its longest side is at most size, averaging the source
+// pixels that fall into each target pixel. Smaller images are returned unchanged.
+func resize(img image.Image, size int) image.Image {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w <= size && h <= size {
+		return img
+	}
+
+	var tw, th int
+	if w >= h {
+		tw, th = size, h*size/w
+	} else {
+		tw, th = w*size/h, size
+	}
+	if tw < 1 {
+		tw = 1
+	}
+	if th < 1 {
+		th = 1
+	}
+
+	dst := image.NewNRGBA(image.Rect(0, 0, tw, th))
+	for y := 0; y < th; y++ {
+		y0, y1 := b.Min.Y+y*h/th, b.Min.Y+(y+1)*h/th
+		if y1 == y0 {
+			y1++
+		}
+		for x := 0; x < tw; x++ {
+			x0, x1 := b.Min.X+x*w/tw, b.Min.X+(x+1)*w/tw
+			if x1 == x0 {
+				x1++
+			}
+
+			var r, g, bl, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					c := color.NRGBA64Model.Convert(img.At(sx, sy)).(color.NRGBA64)
+					r += uint64(c.R)
+					g += uint64(c.G)
+					bl += uint64(c.B)
+					a += uint64(c.A)
+					n++
+				}
+			}
+			dst.SetNRGBA(x, y, color.NRGBA{
+				R: uint8(r / n >> 8),
+				G: uint8(g / n >> 8),
+				B: uint8(bl / n >> 8),
+				A: uint8(a / n >> 8),
+			})
+		}
+	}
+	return dst
+}
+
+// orient applies an EXIF orientation (1-8) so the thumbnail displays upright.
+func orient(img image.Image, orientation int) image.Image {
+	if orientation < 2 || orientation > 8 {
+		return img
+	}
+
+	src := image.NewNRGBA(img.Bounds())
+	draw.Draw(src, src.Bounds(), img, img.Bounds().Min, draw.Src)
+	w, h := src.Bounds().Dx(), src.Bounds().Dy()
+
+	dw, dh := w, h
+	if orientation >= 5 {
+		dw, dh = h, w
+	}
+	dst := image.NewNRGBA(image.Rect(0, 0, dw, dh))
+
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var dx, dy int
+			switch orientation {
+			case 2:
+				dx, dy = w-1-x, y
+			case 3:
+				dx, dy = w-1-x, h-1-y
+			case 4:
+				dx, dy = x, h-1-y
+			case 5:
+				dx, dy = y, x
+			case 6:
+				dx, dy = h-1-y, x
+			case 7:
+				dx, dy = h-1-y, w-1-x
+			case 8:
+				dx, dy = y, w-1-x
+			}
+			dst.SetNRGBA(dx, dy, src.NRGBAAt(src.Bounds().Min.X+x, src.Bounds().Min.Y+y))
+		}
+	}
+	return dst
+}
+
+// stripJPEGMetadata drops the APP1 (EXIF, XMP), APP13 (IPTC) and comment segments
+// without re-encoding the image. The orientation is kept in a minimal EXIF block
+// so the photo still displays the right way up.
+func stripJPEGMetadata(data []byte) ([]byte, int, error) {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return nil, 1, errors.New("not a JPEG")
+	}
+
+	orientation := 1
+	var app0, kept bytes.Buffer
+
+	i := 2
+	for i+4 <= len(data) {
+		if data[i] != 0xFF {
+			return nil, 1, errors.New("malformed JPEG")
+		}
+		marker := data[i+1]
+
+		//Markers may be padded with any number of fill bytes
+		if marker == 0xFF {
+			i++
+			continue
+		}
+
+		//Start of scan: everything from here on is image data
+		if marker == 0xDA {
+			break
+		}
+
+		length := int(binary.BigEndian.Uint16(data[i+2 : i+4]))
+		end := i + 2 + length
+		if length < 2 || end > len(data) {
+			return nil, 1, errors.New("malformed JPEG")
+		}
+		segment := data[i:end]
+
+		switch marker {
+		case 0xE1:
+			if o := exifOrientation(segment[4:]); o != 0 {
+				orientation = o
+			}
+		case 0xED, 0xFE:
+		case 0xE0:
+			app0.Write(segment)
+		default:
+			kept.Write(segment)
+		}
+		i = end
+	}
+
+	//JFIF requires its APP0 segment to come first
+	out := bytes.NewBuffer(make([]byte, 0, len(data)))
+	out.Write(data[:2])
+	out.Write(app0.Bytes())
+	if orientation != 1 {
+		out.Write(orientationSegment(orientation))
+	}
+	out.Write(kept.Bytes())
+	out.Write(data[i:])
+	return out.Bytes(), orientation, nil
+}
+
+// exifOrientation reads the Orientation tag from an APP1 payload, or returns 0.
+func exifOrientation(payload []byte) int {
+	if len(payload) < 14 || string(payload[:6]) != "Exif\x00\x00" {
+		return 0
+	}
+	tiff := payload[6:]
+
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 0
+	}
+
+	offset := int(order.Uint32(tiff[4:8]))
+	if offset+2 > len(tiff) {
+		return 0
+	}
+	count := int(order.Uint16(tiff[offset : offset+2]))
+	for n := 0; n < count; n++ {
+		entry := offset + 2 + n*12
+		if entry+12 > len(tiff) {
+			return 0
+		}
+		if order.Uint16(tiff[entry:entry+2]) == 0x0112 {
+			o := int(order.Uint16(tiff[entry+8 : entry+10]))
+			if o >= 1 && o <= 8 {
+				return o
+			}
+			return 0
+		}
+	}
+	return 0
+}
+
+// orientationSegment builds an APP1 segment whose EXIF data holds nothing but the orientation.
+func orientationSegment(orientation int) []byte {
+	payload := []byte("Exif\x00\x00" +
+		"MM\x00\x2A\x00\x00\x00\x08" + //Big-endian TIFF header, IFD0 right after it
+		"\x00\x01" + //One entry
+		"\x01\x12\x00\x03\x00\x00\x00\x01" + //Orientation, SHORT, count 1
+		string([]byte{0x00, byte(orientation), 0x00, 0x00}) +
+		"\x00\x00\x00\x00") //No next IFD
+
+	segment := []byte{0xFF, 0xE1, 0, 0}
+	binary.BigEndian.PutUint16(segment[2:], uint16(len(payload)+2))
+	return append(segment, payload...)
+}
+
+var pngSignature = []byte("\x89PNG\r\n\x1a\n")
+
+// stripPNGMetadata drops the eXIf chunk and the text chunks XMP is stored in.
+func stripPNGMetadata(data []byte) ([]byte, error) {
+	if !bytes.HasPrefix(data, pngSignature) {
+		return nil, errors.New("not a PNG")
+	}
+
+	out := bytes.NewBuffer(make([]byte, 0, len(data)))
+	out.Write(pngSignature)
+
+	i := len(pngSignature)
+	for i+12 <= len(data) {
+		length := int(binary.BigEndian.Uint32(data[i : i+4]))
+		end := i + 12 + length
+		if length < 0 || end > len(data) {
+			return nil, errors.New("malformed PNG")
+		}
+
+		chunk := data[i:end]
+		kind := string(chunk[4:8])
+		if crc32.ChecksumIEEE(chunk[4:8+length]) != binary.BigEndian.Uint32(chunk[8+length:]) {
+			return nil, errors.New("malformed PNG")
+		}
+
+		switch kind {
+		case "eXIf", "tEXt", "zTXt", "iTXt":
+		default:
+			out.Write(chunk)
+		}
+		i = end
+
+		if kind == "IEND" {
+			break
+		}
+	}
+	return out.Bytes(), nil
+}
+
+// stripGIFMetadata drops comment extensions and every application extension except the
+// ones that control looping, which is where XMP is stored.
+func stripGIFMetadata(data []byte) ([]byte, error) {
+	malformed := errors.New("malformed GIF")
+
+	if len(data) < 13 || (string(data[:6]) != "GIF87a" && string(data[:6]) != "GIF89a") {
+		return nil, errors.New("not a GIF")
+	}
+
+	//Header, logical screen descriptor and the global color table if there is one
+	i := 13
+	if data[10]&0x80 != 0 {
+		i += 3 << (data[10]&0x07 + 1)
+	}
+	if i > len(data) {
+		return nil, malformed
+	}
+
+	out := bytes.NewBuffer(make([]byte, 0, len(data)))
+	out.Write(data[:i])
+
+	//skipSubBlocks returns where the data sub-blocks starting at j end
+	skipSubBlocks := func(j int) (int, error) {
+		for j < len(data) {
+			size := int(data[j])
+			j++
+			if size == 0 {
+				return j, nil
+			}
+			j += size
+		}
+		return 0, malformed
+	}
+
+	for i < len(data) {
+		start := i
+
+		switch data[i] {
+		case 0x3B: //Trailer
+			out.WriteByte(0x3B)
+			return out.Bytes(), nil
+
+		case 0x21: //Extension
+			if i+2 > len(data) {
+				return nil, malformed
+			}
+			label := data[i+1]
+			end, err := skipSubBlocks(i + 2)
+			if err != nil {
+				return nil, err
+			}
+			i = end
+
+			keep := true
+			switch label {
+			case 0xFE: //Comment
+				keep = false
+			case 0xFF: //Application
+				id := data[start+2 : min(start+3+int(data[start+2]), end)]
+				keep = bytes.HasPrefix(id, []byte{11, 'N', 'E', 'T', 'S', 'C', 'A', 'P', 'E', '2', '.', '0'}) ||
+					bytes.HasPrefix(id, []byte{11, 'A', 'N', 'I', 'M', 'E', 'X', 'T', 'S', '1', '.', '0'})
+			}
+			if keep {
+				out.Write(data[start:end])
+			}
+
+		case 0x2C: //Image descriptor, local color table, LZW code size and image data
+			if i+11 > len(data) {
+				return nil, malformed
+			}
+			j := i + 10
+			if data[i+9]&0x80 != 0 {
+				j += 3 << (data[i+9]&0x07 + 1)
+			}
+			end, err := skipSubBlocks(j + 1)
+			if err != nil {
+				return nil, err
+			}
+			i = end
+			out.Write(data[start:end])
+
+		default:
+			return nil, malformed
+		}
+	}
+
+	//Some encoders leave the trailer off, which decoders put up with
+	return out.Bytes(), nil
+}
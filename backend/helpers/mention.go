@@ -0,0 +1,98 @@
+package helpers
+
+import (
+	"context"
+	"strings"
+	"unicode"
+
+	"github.com/shjung-dev/ChatApplication/backend/models"
+)
+
+// maxMentions caps how many people a single message can address by name.
+const maxMentions = 50
+
+// Mentions is what a message's content addresses.
+type Mentions struct {
+	Usernames []string //Distinct names written as @username, not yet checked against anything
+	All       bool     //@all: everyone in the conversation
+	Here      bool     //@here: everyone in the conversation who is online
+}
+
+// ParseMentions picks @username, @all and @here out of content. A mention runs until the
+// next whitespace; punctuation directly after it ("@bob,") is tried both with and without.
+func ParseMentions(content string) Mentions {
+	var result Mentions
+	seen := make(map[string]bool)
+
+	for _, word := range strings.FieldsFunc(content, unicode.IsSpace) {
+		if len(word) < 2 || word[0] != '@' {
+			continue
+		}
+
+		name := word[1:]
+		trimmed := strings.TrimRightFunc(name, unicode.IsPunct)
+
+		switch trimmed {
+		case "all":
+			result.All = true
+			continue
+		case "here":
+			result.Here = true
+			continue
+		}
+
+		for _, candidate := range []string{name, trimmed} {
+			if candidate == "" || seen[candidate] || len(result.Usernames) >= maxMentions {
+				continue
+			}
+			seen[candidate] = true
+			result.Usernames = append(result.Usernames, candidate)
+		}
+	}
+	return result
+}
+
+// ResolveMentions keeps the mentioned users that actually belong to convo.
+func ResolveMentions(ctx context.Context, convo models.Conversation, usernames []string) ([]models.UserRef, error) {
+	if len(usernames) == 0 {
+		return nil, nil
+	}
+
+	users, err := LookupUsers(ctx, usernames)
+	if err != nil {
+		return nil, err
+	}
+
+	if !convo.IsChannel() {
+		mentioned := []models.UserRef{}
+		for _, u := range users {
+			if convo.IsParticipant(u.ID) {
+				mentioned = append(mentioned, u)
+			}
+		}
+		return mentioned, nil
+	}
+
+	ids := make([]string, 0, len(users))
+	for _, u := range users {
+		ids = append(ids, u.ID)
+	}
+
+	memberIDs, err := FilterChannelMembers(ctx, convo.ConversationID, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	members := make(map[string]bool, len(memberIDs))
+	for _, id := range memberIDs {
+		members[id] = true
+	}
+
+	mentioned := []models.UserRef{}
+	for _, u := range users {
+		if members[u.ID] {
+			mentioned = append(mentioned, u)
+		}
+	}
+	return mentioned, nil
+}
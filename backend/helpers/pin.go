@@ -0,0 +1,86 @@
+package helpers
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/shjung-dev/ChatApplication/backend/config"
+	"github.com/shjung-dev/ChatApplication/backend/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// maxPins caps how many messages a conversation can have pinned at once.
+const maxPins = 50
+
+var ErrTooManyPins = errors.New("this conversation already has the maximum number of pinned messages")
+
+// PinMessage pins m in its conversation. It reports false if m was already pinned.
+func PinMessage(ctx context.Context, m models.Message, userID string) (bool, error) {
+	pin := models.PinnedMessage{
+		MessageID:  m.ID,
+		PinnedByID: userID,
+		PinnedAt:   time.Now(),
+	}
+
+	filter := bson.M{
+		"conversationID":           m.ConversationID,
+		"pinnedMessages.messageID": bson.M{"$ne": m.ID},
+	}
+	//Checking the limit in the filter keeps concurrent pins from overshooting it
+	filter["pinnedMessages."+strconv.Itoa(maxPins-1)] = bson.M{"$exists": false}
+
+	convoCollection := config.OpenCollection("conversation")
+	result, err := convoCollection.UpdateOne(ctx, filter, bson.M{"$push": bson.M{"pinnedMessages": pin}})
+	if err != nil {
+		return false, err
+	}
+	if result.MatchedCount > 0 {
+		return true, nil
+	}
+
+	pinned, err := convoCollection.CountDocuments(ctx, bson.M{
+		"conversationID":           m.ConversationID,
+		"pinnedMessages.messageID": m.ID,
+	})
+	if err != nil {
+		return false, err
+	}
+	if pinned > 0 {
+		return false, nil
+	}
+	return false, ErrTooManyPins
+}
+
+// UnpinMessage unpins messageID from convoID. It reports false if it wasn't pinned.
+func UnpinMessage(ctx context.Context, convoID string, messageID primitive.ObjectID) (bool, error) {
+	result, err := config.OpenCollection("conversation").UpdateOne(ctx,
+		bson.M{"conversationID": convoID},
+		bson.M{"$pull": bson.M{"pinnedMessages": bson.M{"messageID": messageID}}},
+	)
+	if err != nil {
+		return false, err
+	}
+	return result.ModifiedCount > 0, nil
+}
+
+// PinnedMessages returns the current pins of convoID, oldest first.
+func PinnedMessages(ctx context.Context, convoID string) ([]models.PinnedMessage, error) {
+	var convo models.Conversation
+
+	err := config.OpenCollection("conversation").FindOne(ctx,
+		bson.M{"conversationID": convoID},
+		options.FindOne().SetProjection(bson.M{"pinnedMessages": 1}),
+	).Decode(&convo)
+	if err != nil {
+		return nil, err
+	}
+
+	if convo.PinnedMessages == nil {
+		return []models.PinnedMessage{}, nil
+	}
+	return convo.PinnedMessages, nil
+}
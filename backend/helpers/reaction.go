@@ -0,0 +1,161 @@
+package helpers
+
+import (
+	"context"
+	"errors"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/shjung-dev/ChatApplication/backend/config"
+	"github.com/shjung-dev/ChatApplication/backend/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// maxReactionKinds caps how many different emoji a single message can collect.
+const maxReactionKinds = 20
+
+var (
+	ErrInvalidEmoji         = errors.New("invalid emoji")
+	ErrTooManyReactionKinds = errors.New("this message has too many different reactions")
+)
+
+// ValidEmoji accepts a single emoji, including skin tone, flag, keycap and ZWJ sequences.
+// It is deliberately loose: the point is to keep text and markup out, not to track Unicode.
+func ValidEmoji(emoji string) bool {
+	if emoji == "" || len(emoji) > 64 || !utf8.ValidString(emoji) || utf8.RuneCountInString(emoji) > 16 {
+		return false
+	}
+
+	symbol := false
+	for _, r := range emoji {
+		switch {
+		case unicode.IsSpace(r), unicode.IsControl(r), unicode.IsLetter(r):
+			return false
+		case unicode.Is(unicode.So, r), r == 0x20E3: //Symbols, or the keycap combining mark
+			symbol = true
+		}
+	}
+	return symbol
+}
+
+// AddReaction records userID's emoji on m. It reports false if they had already reacted with it.
+func AddReaction(ctx context.Context, m models.Message, userID string, emoji string) (bool, error) {
+	if !ValidEmoji(emoji) {
+		return false, ErrInvalidEmoji
+	}
+
+	reactionCollection := config.OpenCollection("reaction")
+
+	kinds, err := reactionCollection.Distinct(ctx, "emoji", bson.M{"message_id": m.ID})
+	if err != nil {
+		return false, err
+	}
+	if len(kinds) >= maxReactionKinds {
+		known := false
+		for _, k := range kinds {
+			if k == emoji {
+				known = true
+				break
+			}
+		}
+		if !known {
+			return false, ErrTooManyReactionKinds
+		}
+	}
+
+	_, err = reactionCollection.InsertOne(ctx, models.Reaction{
+		ID:             primitive.NewObjectID(),
+		MessageID:      m.ID,
+		ConversationID: m.ConversationID,
+		UserID:         userID,
+		Emoji:          emoji,
+		CreatedAt:      time.Now(),
+	})
+	if mongo.IsDuplicateKeyError(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// RemoveReaction takes userID's emoji off messageID. It reports false if there was nothing to remove.
+func RemoveReaction(ctx context.Context, messageID primitive.ObjectID, userID string, emoji string) (bool, error) {
+	result, err := config.OpenCollection("reaction").DeleteOne(ctx, bson.M{
+		"message_id": messageID,
+		"user_id":    userID,
+		"emoji":      emoji,
+	})
+	if err != nil {
+		return false, err
+	}
+	return result.DeletedCount > 0, nil
+}
+
+// ReactionCounts aggregates the reactions on messageIDs, in the order each emoji was first used.
+// Reacted is set on the counts viewerID contributed to.
+func ReactionCounts(ctx context.Context, messageIDs []primitive.ObjectID, viewerID string) (map[primitive.ObjectID][]models.ReactionCount, error) {
+	counts := make(map[primitive.ObjectID][]models.ReactionCount)
+	if len(messageIDs) == 0 {
+		return counts, nil
+	}
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"message_id": bson.M{"$in": messageIDs}}}},
+		{{Key: "$group", Value: bson.M{
+			"_id":     bson.M{"message_id": "$message_id", "emoji": "$emoji"},
+			"count":   bson.M{"$sum": 1},
+			"first":   bson.M{"$min": "$created_at"},
+			"reacted": bson.M{"$max": bson.M{"$eq": bson.A{"$user_id", viewerID}}},
+		}}},
+		{{Key: "$sort", Value: bson.D{{Key: "first", Value: 1}}}},
+	}
+
+	cursor, err := config.OpenCollection("reaction").Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+
+	defer cursor.Close(ctx)
+
+	var groups []struct {
+		ID struct {
+			MessageID primitive.ObjectID `bson:"message_id"`
+			Emoji     string             `bson:"emoji"`
+		} `bson:"_id"`
+		Count   int  `bson:"count"`
+		Reacted bool `bson:"reacted"`
+	}
+
+	if err := cursor.All(ctx, &groups); err != nil {
+		return nil, err
+	}
+
+	for _, g := range groups {
+		counts[g.ID.MessageID] = append(counts[g.ID.MessageID], models.ReactionCount{
+			Emoji:   g.ID.Emoji,
+			Count:   g.Count,
+			Reacted: g.Reacted,
+		})
+	}
+	return counts, nil
+}
+
+// AttachReactions fills in the reaction counts of messages as viewerID sees them.
+func AttachReactions(ctx context.Context, messages []models.Message, viewerID string) error {
+	ids := make([]primitive.ObjectID, 0, len(messages))
+	for _, m := range messages {
+		ids = append(ids, m.ID)
+	}
+
+	counts, err := ReactionCounts(ctx, ids, viewerID)
+	if err != nil {
+		return err
+	}
+
+	for i := range messages {
+		messages[i].Reactions = counts[messages[i].ID]
+	}
+	return nil
+}
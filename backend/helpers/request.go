@@ -0,0 +1,218 @@
+package helpers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/shjung-dev/ChatApplication/backend/config"
+	"github.com/shjung-dev/ChatApplication/backend/models"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+type FriendRequestPolicy struct {
+	TTL        time.Duration //How long a pending request lives. 0 keeps it forever
+	DailyLimit int           //Outgoing requests allowed per user per 24 hours. 0 means unlimited
+	Cooldown   time.Duration //How long after a rejection before the same user may ask again
+}
+
+var friendRequestPolicy = FriendRequestPolicy{
+	TTL:        30 * 24 * time.Hour,
+	DailyLimit: 50,
+	Cooldown:   24 * time.Hour,
+}
+
+func SetFriendRequestPolicy(policy FriendRequestPolicy) {
+	friendRequestPolicy = policy
+}
+
+func GetFriendRequestPolicy() FriendRequestPolicy {
+	return friendRequestPolicy
+}
+
+var (
+	ErrDailyRequestLimit = errors.New("daily friend request limit reached")
+	ErrRequestCooldown   = errors.New("this user recently rejected your request")
+)
+
+// CheckFriendRequestAllowed enforces the daily cap and the cool-down after a rejection.
+func CheckFriendRequestAllowed(ctx context.Context, fromID string, toID string) error {
+	now := time.Now()
+
+	requestCollection := config.OpenCollection("request")
+	count, err := requestCollection.CountDocuments(ctx, bson.M{
+		"from_id":   fromID,
+		"to_id":     toID,
+		"status":    "rejected",
+		"expiresAt": bson.M{"$gt": now},
+	})
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrRequestCooldown
+	}
+
+	if friendRequestPolicy.DailyLimit <= 0 {
+		return nil
+	}
+
+	//The log outlives cancelled and expired requests, so withdrawing doesn't refund the quota
+	logCollection := config.OpenCollection("request_log")
+	count, err = logCollection.CountDocuments(ctx, bson.M{
+		"from_id":    fromID,
+		"created_at": bson.M{"$gt": now.Add(-24 * time.Hour)},
+	})
+	if err != nil {
+		return err
+	}
+	if count >= int64(friendRequestPolicy.DailyLimit) {
+		return ErrDailyRequestLimit
+	}
+	return nil
+}
+
+// InsertFriendRequest stores a new pending request and counts it towards the sender's daily cap.
+func InsertFriendRequest(ctx context.Context, from models.UserRef, to models.UserRef) error {
+	now := time.Now()
+
+	request := models.Request{
+		FromID:    from.ID,
+		ToID:      to.ID,
+		From:      from.Username,
+		To:        to.Username,
+		Status:    "pending",
+		CreatedAt: now,
+	}
+	if friendRequestPolicy.TTL > 0 {
+		expiresAt := now.Add(friendRequestPolicy.TTL)
+		request.ExpiresAt = &expiresAt
+	}
+
+	requestCollection := config.OpenCollection("request")
+
+	//An old rejection whose cool-down passed but hasn't been swept yet is no longer relevant
+	_, err := requestCollection.DeleteMany(ctx, bson.M{
+		"from_id": from.ID,
+		"to_id":   to.ID,
+		"status":  "rejected",
+	})
+	if err != nil {
+		return err
+	}
+
+	if _, err := requestCollection.InsertOne(ctx, request); err != nil {
+		return err
+	}
+
+	logCollection := config.OpenCollection("request_log")
+	_, err = logCollection.InsertOne(ctx, bson.M{
+		"from_id":    from.ID,
+		"created_at": now,
+	})
+	return err
+}
+
+// RejectFriendRequest answers the pending request from sender to rejecter.
+// The rejection is kept until the cool-down ends so the sender cannot ask again straight away.
+func RejectFriendRequest(ctx context.Context, senderID string, rejecterID string) (bool, error) {
+	filter := bson.M{
+		"from_id": senderID,
+		"to_id":   rejecterID,
+		"status":  "pending",
+	}
+
+	requestCollection := config.OpenCollection("request")
+
+	if friendRequestPolicy.Cooldown <= 0 {
+		result, err := requestCollection.DeleteOne(ctx, filter)
+		if err != nil {
+			return false, err
+		}
+		return result.DeletedCount > 0, nil
+	}
+
+	result, err := requestCollection.UpdateOne(ctx, filter, bson.M{
+		"$set": bson.M{
+			"status":    "rejected",
+			"expiresAt": time.Now().Add(friendRequestPolicy.Cooldown),
+		},
+	})
+	if err != nil {
+		return false, err
+	}
+	return result.MatchedCount > 0, nil
+}
+
+// BackfillRequestExpiry gives pending requests created before expiry existed an expiry date.
+func BackfillRequestExpiry(ctx context.Context) {
+	if friendRequestPolicy.TTL <= 0 {
+		return
+	}
+
+	requestCollection := config.OpenCollection("request")
+	result, err := requestCollection.UpdateMany(ctx,
+		bson.M{"status": "pending", "expiresAt": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"expiresAt": time.Now().Add(friendRequestPolicy.TTL)}},
+	)
+	if err != nil {
+		log.Println("Failed to backfill request expiry:", err)
+		return
+	}
+
+	if result.ModifiedCount > 0 {
+		log.Printf("Set expiry on %d pending requests", result.ModifiedCount)
+	}
+}
+
+// OutgoingRequests returns every friend request the user has sent, whatever its status.
+func OutgoingRequests(ctx context.Context, userID string) ([]models.Request, error) {
+	requestCollection := config.OpenCollection("request")
+	cursor, err := requestCollection.Find(ctx, bson.M{"from_id": userID})
+	if err != nil {
+		return nil, err
+	}
+
+	defer cursor.Close(ctx)
+
+	requests := []models.Request{}
+
+	if err := cursor.All(ctx, &requests); err != nil {
+		return nil, err
+	}
+	return requests, nil
+}
+
+// AcceptFriendRequest marks the pending request from sender to accepter as accepted
+// and makes them friends. It reports false if there was no such pending request.
+func AcceptFriendRequest(ctx context.Context, sender models.UserRef, accepter models.UserRef) (bool, error) {
+	filter := bson.M{
+		"from_id": sender.ID,
+		"to_id":   accepter.ID,
+		"status":  "pending",
+	}
+
+	update := bson.M{
+		"$set": bson.M{
+			"status": "accepted",
+		},
+		"$unset": bson.M{
+			"expiresAt": "",
+		},
+	}
+
+	requestCollection := config.OpenCollection("request")
+
+	result, err := requestCollection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return false, err
+	}
+
+	if result.MatchedCount == 0 {
+		return false, nil
+	}
+
+	//Both users get each other in their friend list
+	return true, AddFriendship(ctx, accepter, sender)
+}
@@ -0,0 +1,194 @@
+package helpers
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shjung-dev/ChatApplication/backend/config"
+	"github.com/shjung-dev/ChatApplication/backend/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	maxScheduleAhead    = 365 * 24 * time.Hour
+	maxScheduledPerUser = 100
+)
+
+var (
+	ErrScheduleInPast    = errors.New("send time must be in the future")
+	ErrScheduleTooFar    = errors.New("send time must be within a year")
+	ErrTooManyScheduled  = errors.New("you have too many scheduled messages")
+	ErrScheduledNotFound = errors.New("scheduled message not found")
+)
+
+func checkSendAt(sendAt time.Time) error {
+	now := time.Now()
+	if !sendAt.After(now) {
+		return ErrScheduleInPast
+	}
+	if sendAt.After(now.Add(maxScheduleAhead)) {
+		return ErrScheduleTooFar
+	}
+	return nil
+}
+
+// ScheduleMessage stores s to be sent at s.SendAt. Only the content and targeting fields of s are used.
+func ScheduleMessage(ctx context.Context, s models.ScheduledMessage) (models.ScheduledMessage, error) {
+	if err := checkSendAt(s.SendAt); err != nil {
+		return s, err
+	}
+
+	scheduledCollection := config.OpenCollection("scheduled")
+
+	count, err := scheduledCollection.CountDocuments(ctx, bson.M{"user_id": s.UserID})
+	if err != nil {
+		return s, err
+	}
+	if count >= maxScheduledPerUser {
+		return s, ErrTooManyScheduled
+	}
+
+	now := time.Now()
+	s.ID = primitive.NewObjectID()
+	s.ScheduleID = uuid.NewString()
+	s.Status = models.ScheduledPending
+	s.Error = ""
+	s.CreatedAt = now
+	s.UpdatedAt = now
+
+	_, err = scheduledCollection.InsertOne(ctx, s)
+	return s, err
+}
+
+// ListScheduled returns the user's scheduled messages, soonest first.
+func ListScheduled(ctx context.Context, userID string) ([]models.ScheduledMessage, error) {
+	scheduled := []models.ScheduledMessage{}
+
+	err := findAll(ctx, "scheduled", bson.M{"user_id": userID}, &scheduled,
+		options.Find().SetSort(bson.D{{Key: "send_at", Value: 1}}),
+	)
+	return scheduled, err
+}
+
+// UpdateScheduled changes the content and/or send time of a message that hasn't gone out yet.
+// Editing a failed message queues it again.
+func UpdateScheduled(ctx context.Context, userID string, scheduleID string, content *string, sendAt *time.Time) (models.ScheduledMessage, error) {
+	var s models.ScheduledMessage
+
+	set := bson.M{
+		"status":     models.ScheduledPending,
+		"updated_at": time.Now(),
+	}
+	if content != nil {
+		set["content"] = *content
+	}
+	if sendAt != nil {
+		if err := checkSendAt(*sendAt); err != nil {
+			return s, err
+		}
+		set["send_at"] = *sendAt
+	}
+
+	scheduledCollection := config.OpenCollection("scheduled")
+	err := scheduledCollection.FindOneAndUpdate(ctx,
+		bson.M{
+			"schedule_id": scheduleID,
+			"user_id":     userID,
+			"status":      bson.M{"$in": bson.A{models.ScheduledPending, models.ScheduledFailed}},
+		},
+		bson.M{"$set": set, "$unset": bson.M{"error": ""}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&s)
+	if err == mongo.ErrNoDocuments {
+		return s, ErrScheduledNotFound
+	}
+	return s, err
+}
+
+// CancelScheduled deletes a message that hasn't gone out yet.
+func CancelScheduled(ctx context.Context, userID string, scheduleID string) error {
+	result, err := config.OpenCollection("scheduled").DeleteOne(ctx, bson.M{
+		"schedule_id": scheduleID,
+		"user_id":     userID,
+		"status":      bson.M{"$in": bson.A{models.ScheduledPending, models.ScheduledFailed}},
+	})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return ErrScheduledNotFound
+	}
+	return nil
+}
+
+// ClaimDueScheduled marks the next message that is due as sending and returns it.
+// The claim is atomic, so several servers can run the scheduler without sending twice.
+func ClaimDueScheduled(ctx context.Context) (models.ScheduledMessage, error) {
+	var s models.ScheduledMessage
+
+	err := config.OpenCollection("scheduled").FindOneAndUpdate(ctx,
+		bson.M{"status": models.ScheduledPending, "send_at": bson.M{"$lte": time.Now()}},
+		bson.M{"$set": bson.M{"status": models.ScheduledSending, "updated_at": time.Now()}},
+		options.FindOneAndUpdate().
+			SetSort(bson.D{{Key: "send_at", Value: 1}}).
+			SetReturnDocument(options.After),
+	).Decode(&s)
+	return s, err
+}
+
+// FinishScheduled removes a sent message, or records why it couldn't be sent.
+func FinishScheduled(ctx context.Context, s models.ScheduledMessage, sendErr string) error {
+	scheduledCollection := config.OpenCollection("scheduled")
+
+	if sendErr == "" {
+		_, err := scheduledCollection.DeleteOne(ctx, bson.M{"schedule_id": s.ScheduleID})
+		return err
+	}
+
+	_, err := scheduledCollection.UpdateOne(ctx,
+		bson.M{"schedule_id": s.ScheduleID},
+		bson.M{"$set": bson.M{
+			"status":     models.ScheduledFailed,
+			"error":      sendErr,
+			"updated_at": time.Now(),
+		}},
+	)
+	return err
+}
+
+// FailInterruptedScheduled marks messages left half-sent by a previous run as failed.
+// Retrying them automatically could post the same message twice.
+func FailInterruptedScheduled(ctx context.Context) error {
+	_, err := config.OpenCollection("scheduled").UpdateMany(ctx,
+		bson.M{"status": models.ScheduledSending},
+		bson.M{"$set": bson.M{
+			"status":     models.ScheduledFailed,
+			"error":      "Sending was interrupted, please check the conversation before retrying",
+			"updated_at": time.Now(),
+		}},
+	)
+	return err
+}
+
+// scheduledAttachmentIDs returns the uploads that scheduled messages are still waiting to send.
+func scheduledAttachmentIDs(ctx context.Context) ([]string, error) {
+	var scheduled []models.ScheduledMessage
+
+	err := findAll(ctx, "scheduled", bson.M{"attachments.0": bson.M{"$exists": true}}, &scheduled,
+		options.Find().SetProjection(bson.M{"attachments": 1}),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := []string{}
+	for _, s := range scheduled {
+		ids = append(ids, s.Attachments...)
+	}
+	return ids, nil
+}
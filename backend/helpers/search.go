@@ -0,0 +1,212 @@
+package helpers
+
+import (
+	"context"
+	"html"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/shjung-dev/ChatApplication/backend/config"
+	"github.com/shjung-dev/ChatApplication/backend/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	snippetBefore = 40  //Characters of context kept ahead of the first match
+	snippetLength = 160 //Characters in a snippet, not counting the ellipses
+)
+
+// MessageSearch is a full-text query over the messages a user can read.
+// Zero values leave a filter out.
+type MessageSearch struct {
+	UserID         string
+	Query          string //Words, "quoted phrases" and -excluded words, as understood by Mongo $text
+	ConversationID string
+	SenderID       string
+	From           time.Time
+	To             time.Time
+	Skip           int
+	Limit          int
+}
+
+type SearchResult struct {
+	Message models.Message `json:"message"`
+	Snippet string         `json:"snippet"` //HTML-escaped, with the matched terms wrapped in <mark>
+	Score   float64        `json:"score"`
+}
+
+// SearchMessages runs s and returns one page of results, best match first, and the total number of matches.
+// A conversation the user can't read yields nothing rather than an error.
+func SearchMessages(ctx context.Context, s MessageSearch) ([]SearchResult, int64, error) {
+	convoIDs, err := ConversationIDsForUser(ctx, s.UserID)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if s.ConversationID != "" {
+		allowed := false
+		for _, id := range convoIDs {
+			if id == s.ConversationID {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return []SearchResult{}, 0, nil
+		}
+		convoIDs = []string{s.ConversationID}
+	}
+
+	filter := bson.M{
+		"$text":          bson.M{"$search": s.Query},
+		"conversationID": bson.M{"$in": convoIDs},
+		"expiresAt":      Unexpired(),
+	}
+	if s.SenderID != "" {
+		filter["senderID"] = s.SenderID
+	}
+
+	createdAt := bson.M{}
+	if !s.From.IsZero() {
+		createdAt["$gte"] = s.From
+	}
+	if !s.To.IsZero() {
+		createdAt["$lt"] = s.To
+	}
+	if len(createdAt) > 0 {
+		filter["created_at"] = createdAt
+	}
+
+	messageCollection := config.OpenCollection("message")
+
+	total, err := messageCollection.CountDocuments(ctx, filter)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var hits []struct {
+		models.Message `bson:",inline"`
+		Score          float64 `bson:"score"`
+	}
+
+	score := bson.M{"$meta": "textScore"}
+	err = findAll(ctx, "message", filter, &hits, options.Find().
+		SetProjection(bson.M{"score": score}).
+		SetSort(bson.D{{Key: "score", Value: score}, {Key: "created_at", Value: -1}}).
+		SetSkip(int64(s.Skip)).
+		SetLimit(int64(s.Limit)),
+	)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	terms := searchTerms(s.Query)
+	results := make([]SearchResult, 0, len(hits))
+	for _, h := range hits {
+		results = append(results, SearchResult{
+			Message: h.Message,
+			Snippet: Snippet(h.Content, terms),
+			Score:   h.Score,
+		})
+	}
+	return results, total, nil
+}
+
+// searchTerms lists the lowercased words and phrases of a $text query that a match contains.
+// Excluded words are left out since they never appear in a result.
+func searchTerms(query string) []string {
+	var terms []string
+
+	for len(query) > 0 {
+		query = strings.TrimLeftFunc(query, unicode.IsSpace)
+
+		if strings.HasPrefix(query, `"`) {
+			end := strings.Index(query[1:], `"`)
+			if end < 0 {
+				end = len(query) - 1
+			}
+			if phrase := strings.TrimSpace(query[1 : end+1]); phrase != "" {
+				terms = append(terms, strings.ToLower(phrase))
+			}
+			query = query[min(end+2, len(query)):]
+			continue
+		}
+
+		end := strings.IndexFunc(query, unicode.IsSpace)
+		if end < 0 {
+			end = len(query)
+		}
+		word := query[:end]
+		query = query[end:]
+
+		word = strings.TrimFunc(word, func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '-' })
+		if word != "" && !strings.HasPrefix(word, "-") {
+			terms = append(terms, strings.ToLower(word))
+		}
+	}
+	return terms
+}
+
+// Snippet cuts the part of content around the first whole-word match of terms
+// and marks every match in it.
+func Snippet(content string, terms []string) string {
+	text := []rune(content)
+	lower := make([]rune, len(text))
+	for i, r := range text {
+		lower[i] = unicode.ToLower(r)
+	}
+
+	type span struct{ start, end int }
+	var matches []span
+
+	for _, term := range terms {
+		t := []rune(term)
+		for i := 0; i+len(t) <= len(lower); i++ {
+			if string(lower[i:i+len(t)]) == term && isWordBoundary(lower, i-1) && isWordBoundary(lower, i+len(t)) {
+				matches = append(matches, span{i, i + len(t)})
+			}
+		}
+	}
+
+	sort.Slice(matches, func(i, j int) bool { return matches[i].start < matches[j].start })
+
+	start := 0
+	if len(matches) > 0 && matches[0].start > snippetBefore {
+		start = matches[0].start - snippetBefore
+	}
+	end := min(start+snippetLength, len(text))
+
+	var b strings.Builder
+	if start > 0 {
+		b.WriteString("…")
+	}
+
+	pos := start
+	for _, m := range matches {
+		//Overlapping matches are already inside an earlier mark
+		if m.start < pos || m.start >= end {
+			continue
+		}
+		b.WriteString(html.EscapeString(string(text[pos:m.start])))
+		b.WriteString("<mark>")
+		b.WriteString(html.EscapeString(string(text[m.start:min(m.end, end)])))
+		b.WriteString("</mark>")
+		pos = min(m.end, end)
+	}
+	b.WriteString(html.EscapeString(string(text[pos:end])))
+
+	if end < len(text) {
+		b.WriteString("…")
+	}
+	return b.String()
+}
+
+func isWordBoundary(text []rune, i int) bool {
+	if i < 0 || i >= len(text) {
+		return true
+	}
+	return !unicode.IsLetter(text[i]) && !unicode.IsDigit(text[i])
+}
@@ -0,0 +1,107 @@
+package helpers
+
+import (
+	"context"
+	"errors"
+
+	"github.com/shjung-dev/ChatApplication/backend/config"
+	"github.com/shjung-dev/ChatApplication/backend/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// replyPreviewLength is how many characters of the quoted message a reply keeps.
+const replyPreviewLength = 200
+
+var (
+	ErrReplyTargetNotFound = errors.New("the message you are replying to doesn't exist")
+	ErrThreadNotFound      = errors.New("thread not found")
+)
+
+// findConversationMessage loads a message by its hex ID, as long as it belongs to convoID.
+func findConversationMessage(ctx context.Context, convoID string, messageID string) (models.Message, error) {
+	var m models.Message
+
+	id, err := primitive.ObjectIDFromHex(messageID)
+	if err != nil {
+		return m, err
+	}
+
+	messageCollection := config.OpenCollection("message")
+	err = messageCollection.FindOne(ctx, bson.M{"_id": id, "conversationID": convoID, "expiresAt": Unexpired()}).Decode(&m)
+	return m, err
+}
+
+// ReplyPreviewFor snapshots the message being quoted by a reply in convoID.
+func ReplyPreviewFor(ctx context.Context, convoID string, messageID string) (*models.ReplyPreview, error) {
+	parent, err := findConversationMessage(ctx, convoID, messageID)
+	if err != nil {
+		return nil, ErrReplyTargetNotFound
+	}
+
+	content := []rune(parent.Content)
+	if len(content) > replyPreviewLength {
+		content = append(content[:replyPreviewLength], '…')
+	}
+
+	return &models.ReplyPreview{
+		MessageID:      parent.ID,
+		SenderID:       parent.SenderID,
+		SenderUserName: parent.SenderUserName,
+		Content:        string(content),
+	}, nil
+}
+
+// FindThreadRoot loads the message a thread reply in convoID hangs off.
+// Threads don't nest, so a message that is itself a thread reply can't be a root.
+func FindThreadRoot(ctx context.Context, convoID string, messageID string) (models.Message, error) {
+	root, err := findConversationMessage(ctx, convoID, messageID)
+	if err != nil || root.ThreadID != nil {
+		return root, ErrThreadNotFound
+	}
+	return root, nil
+}
+
+// RecordThreadReply bumps the root's thread summary for a newly sent reply and returns it.
+func RecordThreadReply(ctx context.Context, root models.Message, reply models.Message) (models.ThreadSummary, error) {
+	participants := bson.A{reply.SenderID}
+	if root.SenderID != "" {
+		participants = append(participants, root.SenderID)
+	}
+
+	var updated models.Message
+
+	messageCollection := config.OpenCollection("message")
+	err := messageCollection.FindOneAndUpdate(ctx,
+		bson.M{"_id": root.ID},
+		bson.M{
+			"$inc":      bson.M{"thread.replyCount": 1},
+			"$max":      bson.M{"thread.lastReplyAt": reply.CreatedAt},
+			"$addToSet": bson.M{"thread.participantIDs": bson.M{"$each": participants}},
+		},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&updated)
+	if err != nil {
+		return models.ThreadSummary{}, err
+	}
+	return *updated.Thread, nil
+}
+
+// ThreadReplies returns one page of the replies in the thread under rootID, oldest first.
+func ThreadReplies(ctx context.Context, rootID primitive.ObjectID, skip int, limit int) ([]models.Message, int64, error) {
+	filter := bson.M{"threadID": rootID, "expiresAt": Unexpired()}
+
+	total, err := config.OpenCollection("message").CountDocuments(ctx, filter)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	replies := []models.Message{}
+	err = findAll(ctx, "message", filter, &replies, options.Find().
+		SetSort(bson.D{{Key: "created_at", Value: 1}}).
+		SetSkip(int64(skip)).
+		SetLimit(int64(limit)),
+	)
+	return replies, total, err
+}
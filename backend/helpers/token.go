@@ -92,6 +92,24 @@ func UpdateAllToken(signedToken string, signedRefreshToken string, userID string
 	return err
 }
 
+// IsCurrentToken reports whether token is the access token currently stored for the user.
+func IsCurrentToken(userID string, token string) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var user struct {
+		Token *string `bson:"token"`
+	}
+
+	userCollection := config.OpenCollection("user")
+	err := userCollection.FindOne(ctx, bson.M{"user_id": userID}).Decode(&user)
+	if err != nil {
+		return false
+	}
+
+	return user.Token != nil && *user.Token == token
+}
+
 func HashPassword(password *string) *string {
 	bytes, err := bcrypt.GenerateFromPassword([]byte(*password), bcrypt.DefaultCost)
 
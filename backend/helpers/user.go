@@ -0,0 +1,275 @@
+package helpers
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/shjung-dev/ChatApplication/backend/config"
+	"github.com/shjung-dev/ChatApplication/backend/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Ref returns the identity carried by the token. Tokens are reissued on rename,
+// so the username in a token that passed authentication is always current.
+func (c *Claims) Ref() models.UserRef {
+	return models.UserRef{ID: c.UserID, Username: c.Username}
+}
+
+// LookupUser resolves a username, as typed by a user, to a stable reference.
+func LookupUser(ctx context.Context, username string) (models.UserRef, error) {
+	var user models.User
+
+	userCollection := config.OpenCollection("user")
+	err := userCollection.FindOne(ctx, bson.M{"username": username},
+		options.FindOne().SetProjection(bson.M{"user_id": 1, "username": 1}),
+	).Decode(&user)
+	if err != nil {
+		return models.UserRef{}, err
+	}
+	return user.Ref(), nil
+}
+
+// UserRefByID returns the current reference for a user ID.
+func UserRefByID(ctx context.Context, userID string) (models.UserRef, error) {
+	var user models.User
+
+	userCollection := config.OpenCollection("user")
+	err := userCollection.FindOne(ctx, bson.M{"user_id": userID},
+		options.FindOne().SetProjection(bson.M{"user_id": 1, "username": 1}),
+	).Decode(&user)
+	if err != nil {
+		return models.UserRef{}, err
+	}
+	return user.Ref(), nil
+}
+
+// LookupUsers resolves usernames to references, silently skipping names that don't exist.
+func LookupUsers(ctx context.Context, usernames []string) ([]models.UserRef, error) {
+	//Unique never returns nil, which Mongo would reject as an $in operand
+	userCollection := config.OpenCollection("user")
+	cursor, err := userCollection.Find(ctx,
+		bson.M{"username": bson.M{"$in": Unique(usernames)}},
+		options.Find().SetProjection(bson.M{"user_id": 1, "username": 1}),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	defer cursor.Close(ctx)
+
+	var users []models.User
+
+	if err := cursor.All(ctx, &users); err != nil {
+		return nil, err
+	}
+
+	refs := make([]models.UserRef, 0, len(users))
+	for _, u := range users {
+		refs = append(refs, u.Ref())
+	}
+	return refs, nil
+}
+
+// Unique returns input without duplicates, keeping the first occurrence of each value.
+func Unique(input []string) []string {
+	seen := make(map[string]struct{})
+	result := make([]string, 0, len(input))
+
+	for _, v := range input {
+		if _, ok := seen[v]; ok {
+			continue
+		}
+		seen[v] = struct{}{}
+		result = append(result, v)
+	}
+
+	return result
+}
+
+// RenameUser changes the user's username and refreshes every display copy of it.
+// References are keyed by user ID, so nothing is lost if a copy fails to update.
+func RenameUser(ctx context.Context, user models.UserRef, newUsername string) error {
+	userCollection := config.OpenCollection("user")
+	_, err := userCollection.UpdateOne(ctx, bson.M{"user_id": user.ID}, bson.M{
+		"$set": bson.M{"username": newUsername, "updated_at": time.Now()},
+	})
+	if err != nil {
+		return err
+	}
+
+	copies := []struct {
+		collection string
+		idField    string
+		nameField  string
+	}{
+		{"friend", "user_id", "username"},
+		{"friend", "friend_user_id", "friendusername"},
+		{"request", "from_id", "from"},
+		{"request", "to_id", "to"},
+		{"block", "blocker_id", "blocker"},
+		{"block", "blocked_id", "blocked"},
+		{"member", "user_id", "username"},
+		{"invite", "createdByID", "createdBy"},
+		{"message", "senderID", "senderUserName"},
+		{"message", "replyTo.senderID", "replyTo.senderUserName"},
+		{"message", "forwardedFrom.senderID", "forwardedFrom.senderUserName"},
+		{"conversation", "createdByID", "createdBy"},
+	}
+
+	for _, cp := range copies {
+		_, err := config.OpenCollection(cp.collection).UpdateMany(ctx,
+			bson.M{cp.idField: user.ID},
+			bson.M{"$set": bson.M{cp.nameField: newUsername}},
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	//Participant and admin names sit in arrays alongside their IDs
+	convoCollection := config.OpenCollection("conversation")
+	for idField, nameField := range map[string]string{"participantIDs": "participants", "adminIDs": "admins"} {
+		_, err := convoCollection.UpdateMany(ctx,
+			bson.M{idField: user.ID},
+			bson.M{"$set": bson.M{nameField + ".$[name]": newUsername}},
+			options.Update().SetArrayFilters(options.ArrayFilters{
+				Filters: []interface{}{bson.M{"name": user.Username}},
+			}),
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return renameMentions(ctx, user.ID, newUsername)
+}
+
+// renameMentions updates the display copy of userID in the mentions stored on messages.
+func renameMentions(ctx context.Context, userID string, newUsername string) error {
+	_, err := config.OpenCollection("message").UpdateMany(ctx,
+		bson.M{"mentions.user_id": userID},
+		bson.M{"$set": bson.M{"mentions.$[mention].username": newUsername}},
+		options.Update().SetArrayFilters(options.ArrayFilters{
+			Filters: []interface{}{bson.M{"mention.user_id": userID}},
+		}),
+	)
+	return err
+}
+
+const userReferencesMigration = "user_references"
+
+// MigrateUserReferences fills in the user ID fields on data written while users were
+// referenced by username. It runs once; a marker in the migration collection records completion.
+func MigrateUserReferences(ctx context.Context) {
+	migrationCollection := config.OpenCollection("migration")
+	count, err := migrationCollection.CountDocuments(ctx, bson.M{"_id": userReferencesMigration})
+	if err != nil {
+		log.Println("Failed to check migrations:", err)
+		return
+	}
+	if count > 0 {
+		return
+	}
+
+	userCollection := config.OpenCollection("user")
+	cursor, err := userCollection.Find(ctx, bson.M{},
+		options.Find().SetProjection(bson.M{"user_id": 1, "username": 1}),
+	)
+	if err != nil {
+		log.Println("Failed to fetch users for migration:", err)
+		return
+	}
+
+	var users []models.User
+
+	if err := cursor.All(ctx, &users); err != nil {
+		log.Println("Failed to decode users for migration:", err)
+		return
+	}
+
+	ids := make(map[string]string, len(users))
+	for _, u := range users {
+		if u.Username != nil {
+			ids[*u.Username] = u.User_id
+		}
+	}
+
+	fields := []struct {
+		collection string
+		nameField  string
+		idField    string
+	}{
+		{"friend", "username", "user_id"},
+		{"friend", "friendusername", "friend_user_id"},
+		{"request", "from", "from_id"},
+		{"request", "to", "to_id"},
+		{"request_log", "from", "from_id"},
+		{"block", "blocker", "blocker_id"},
+		{"block", "blocked", "blocked_id"},
+		{"member", "username", "user_id"},
+		{"invite", "createdBy", "createdByID"},
+		{"message", "senderUserName", "senderID"},
+		{"conversation", "createdBy", "createdByID"},
+	}
+
+	for username, id := range ids {
+		for _, f := range fields {
+			_, err := config.OpenCollection(f.collection).UpdateMany(ctx,
+				bson.M{f.nameField: username, f.idField: bson.M{"$exists": false}},
+				bson.M{"$set": bson.M{f.idField: id}},
+			)
+			if err != nil {
+				log.Println("Failed to migrate", f.collection+"."+f.nameField+":", err)
+				return
+			}
+		}
+	}
+
+	convoCollection := config.OpenCollection("conversation")
+	convoCursor, err := convoCollection.Find(ctx, bson.M{"participantIDs": bson.M{"$exists": false}})
+	if err != nil {
+		log.Println("Failed to fetch conversations for migration:", err)
+		return
+	}
+
+	var convos []models.Conversation
+
+	if err := convoCursor.All(ctx, &convos); err != nil {
+		log.Println("Failed to decode conversations for migration:", err)
+		return
+	}
+
+	toIDs := func(usernames []string) []string {
+		result := []string{}
+		for _, name := range usernames {
+			if id, ok := ids[name]; ok {
+				result = append(result, id)
+			}
+		}
+		return result
+	}
+
+	for _, convo := range convos {
+		set := bson.M{"participantIDs": toIDs(convo.Participants)}
+		if len(convo.Admins) > 0 {
+			set["adminIDs"] = toIDs(convo.Admins)
+		}
+		_, err := convoCollection.UpdateOne(ctx,
+			bson.M{"conversationID": convo.ConversationID},
+			bson.M{"$set": set},
+		)
+		if err != nil {
+			log.Println("Failed to migrate conversation", convo.ConversationID+":", err)
+			return
+		}
+	}
+
+	_, err = migrationCollection.InsertOne(ctx, bson.M{"_id": userReferencesMigration, "completed_at": time.Now()})
+	if err != nil {
+		log.Println("Failed to record migration:", err)
+		return
+	}
+	log.Printf("Migrated user references for %d users", len(ids))
+}
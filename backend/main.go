@@ -1,9 +1,13 @@
 package main
 
 import (
+	"context"
 	"log"
 	"net/http"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
@@ -11,6 +15,7 @@ import (
 	"github.com/shjung-dev/ChatApplication/backend/helpers"
 	"github.com/shjung-dev/ChatApplication/backend/network"
 	"github.com/shjung-dev/ChatApplication/backend/routes"
+	"github.com/shjung-dev/ChatApplication/backend/storage"
 )
 
 func main() {
@@ -27,6 +32,32 @@ func main() {
 	uri := os.Getenv("MONGO_URI")
 
 	config.ConnectDatabase(uri)
+	helpers.SetFriendRequestPolicy(helpers.FriendRequestPolicy{
+		TTL:        envHours("FRIEND_REQUEST_TTL_HOURS", helpers.GetFriendRequestPolicy().TTL),
+		DailyLimit: envInt("FRIEND_REQUEST_DAILY_LIMIT", helpers.GetFriendRequestPolicy().DailyLimit),
+		Cooldown:   envHours("FRIEND_REQUEST_COOLDOWN_HOURS", helpers.GetFriendRequestPolicy().Cooldown),
+	})
+
+	helpers.SetAccountDeletionGrace(envHours("ACCOUNT_DELETION_GRACE_HOURS", 0))
+	helpers.SetExportSettings(os.Getenv("EXPORT_DIR"), envHours("EXPORT_LINK_TTL_HOURS", 24*time.Hour))
+
+	helpers.SetAttachmentPolicy(helpers.AttachmentPolicy{
+		MaxSize:      int64(envInt("ATTACHMENT_MAX_MB", int(helpers.GetAttachmentPolicy().MaxSize>>20))) << 20,
+		AllowedTypes: envList("ATTACHMENT_TYPES", helpers.GetAttachmentPolicy().AllowedTypes),
+	})
+	helpers.SetBlobStore(blobStore())
+
+	//Must run before the ID-keyed unique indexes are created
+	helpers.MigrateUserReferences(context.Background())
+	config.EnsureIndexes()
+	helpers.BackfillFriendships(context.Background())
+	helpers.BackfillRequestExpiry(context.Background())
+	helpers.StartAccountDeletionSweeper(time.Hour)
+	helpers.StartExportSweeper(time.Hour)
+	helpers.StartAttachmentSweeper(time.Hour)
+	network.StartMediaWorkers(envInt("MEDIA_WORKERS", 2))
+	network.StartScheduler(15 * time.Second)
+	network.StartMessageExpirySweeper(30 * time.Second)
 
 	helpers.SetJWTKey(jwtKey)
 
@@ -55,15 +86,20 @@ func main() {
 			return
 		}
 
-		username := claims.Username
+		//Revoked tokens (e.g. after account deletion) must not reopen a socket
+		if !helpers.IsCurrentToken(claims.UserID, token) {
+			c.JSON(http.StatusUnauthorized , gin.H{"error":"Invalid token"})
+			return
+		}
 
-		roomID:=network.GenerateRoomName(username)
+		roomID:=network.GenerateRoomName(claims.UserID)
 		personalRoom := network.GetRoom(roomID)
 
 		req := c.Request
 
 		q := req.URL.Query()
-		q.Set("username", username)
+		q.Set("user_id", claims.UserID)
+		q.Set("username", claims.Username)
 		req.URL.RawQuery = q.Encode()
 		
 		personalRoom.ServeHttp(c.Writer , req)
@@ -75,3 +111,56 @@ func main() {
 	r.Run(":" + port)
 
 }
+
+// envInt reads an integer setting, falling back to def when it is unset or malformed.
+func envInt(key string, def int) int {
+	value, err := strconv.Atoi(os.Getenv(key))
+	if err != nil {
+		return def
+	}
+	return value
+}
+
+func envHours(key string, def time.Duration) time.Duration {
+	return time.Duration(envInt(key, int(def/time.Hour))) * time.Hour
+}
+
+// envList reads a comma separated setting, falling back to def when it is unset.
+func envList(key string, def []string) []string {
+	value := strings.TrimSpace(os.Getenv(key))
+	if value == "" {
+		return def
+	}
+
+	var result []string
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			result = append(result, v)
+		}
+	}
+	return result
+}
+
+// blobStore picks where attachments are kept. BLOB_STORE=s3 works with any
+// S3-compatible service, e.g. a local MinIO at S3_ENDPOINT=http://localhost:9000.
+func blobStore() storage.BlobStore {
+	if os.Getenv("BLOB_STORE") == "s3" {
+		return storage.NewS3Store(
+			os.Getenv("S3_ENDPOINT"),
+			os.Getenv("S3_REGION"),
+			os.Getenv("S3_BUCKET"),
+			os.Getenv("S3_ACCESS_KEY"),
+			os.Getenv("S3_SECRET_KEY"),
+		)
+	}
+
+	dir := os.Getenv("BLOB_DIR")
+	if dir == "" {
+		dir = "uploads"
+	}
+	store, err := storage.NewLocalStore(dir)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return store
+}
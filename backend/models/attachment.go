@@ -0,0 +1,44 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Attachment is an uploaded file. It is uploaded into a conversation first and
+// then claimed by the message that carries it.
+type Attachment struct {
+	ID             primitive.ObjectID  `bson:"_id,omitempty" json:"-"`
+	AttachmentID   string              `bson:"attachment_id" json:"attachment_id"`
+	ConversationID string              `bson:"conversationID" json:"conversationID"`
+	UploaderID     string              `bson:"uploader_id" json:"uploader_id"`
+	MessageID      *primitive.ObjectID `bson:"message_id,omitempty" json:"message_id,omitempty"` //nil until a message claims it
+	Name           string              `bson:"name" json:"name"`
+	MimeType       string              `bson:"mime_type" json:"mime_type"`
+	Size           int64               `bson:"size" json:"size"`
+	Checksum       string              `bson:"checksum" json:"checksum"` //Hex SHA-256 of the contents
+	StorageKey     string              `bson:"storage_key" json:"-"`
+	CreatedAt      time.Time           `bson:"created_at" json:"created_at"`
+
+	//Only set for images, which go through the media pipeline after they are sent
+	Status        string `bson:"status,omitempty" json:"status,omitempty"`
+	Width         int    `bson:"width,omitempty" json:"width,omitempty"`
+	Height        int    `bson:"height,omitempty" json:"height,omitempty"`
+	ThumbWidth    int    `bson:"thumb_width,omitempty" json:"thumb_width,omitempty"`
+	ThumbHeight   int    `bson:"thumb_height,omitempty" json:"thumb_height,omitempty"`
+	ThumbnailKey  string `bson:"thumbnail_key,omitempty" json:"-"`
+	ThumbnailType string `bson:"thumbnail_type,omitempty" json:"-"`
+}
+
+const (
+	AttachmentProcessing = "processing"
+	AttachmentReady      = "ready"
+	AttachmentFailed     = "failed"
+)
+
+// Viewable reports whether people other than the uploader may download the file.
+// Images stay hidden until their metadata has been stripped.
+func (a Attachment) Viewable() bool {
+	return a.Status == "" || a.Status == AttachmentReady
+}
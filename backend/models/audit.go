@@ -0,0 +1,17 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// AuditEntry records a security-relevant action. It references the account by
+// user ID only so that it can outlive the erased account.
+type AuditEntry struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty"`
+	Action    string             `bson:"action"`
+	UserID    string             `bson:"user_id"`
+	Details   string             `bson:"details,omitempty"`
+	CreatedAt time.Time          `bson:"created_at"`
+}
@@ -0,0 +1,16 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type Block struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"-"`
+	BlockerID string             `bson:"blocker_id" json:"blocker_id"`
+	BlockedID string             `bson:"blocked_id" json:"blocked_id"`
+	Blocker   string             `bson:"blocker" json:"blocker"`
+	Blocked   string             `bson:"blocked" json:"blocked"`
+	CreatedAt time.Time          `bson:"created_at" json:"created_at"`
+}
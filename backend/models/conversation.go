@@ -6,11 +6,86 @@ import (
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+const (
+	ConversationDirect  = "direct"
+	ConversationGroup   = "group"
+	ConversationChannel = "channel" //Broadcast-only, members are stored in the member collection
+)
+
 type Conversation struct {
 	ID               primitive.ObjectID `bson:"_id,omitempty"`
 	ConversationID   string             `bson:"conversationID"`
 	ConversationName *string            `bson:"conversationName,omitempty"`
-	Participants     []string           `bson:"participants"`
+	Type             string             `bson:"type,omitempty"`
+	Description      string             `bson:"description,omitempty"`
+	Public           bool               `bson:"public,omitempty"`      //Public channels are listed in the directory and open to self-join
+	ParticipantIDs   []string           `bson:"participantIDs"`        //Empty for channels
+	Participants     []string           `bson:"participants"`          //Usernames matching ParticipantIDs, for display
+	MemberCount      int                `bson:"memberCount,omitempty"` //Only maintained for channels
+	CreatedByID      string             `bson:"createdByID,omitempty"`
+	CreatedBy        string             `bson:"createdBy,omitempty"`
+	AdminIDs         []string           `bson:"adminIDs,omitempty"`
+	Admins           []string           `bson:"admins,omitempty"`
+	PinnedMessages   []PinnedMessage    `bson:"pinnedMessages,omitempty"` //Oldest pin first
+	MessageExpiry    int64              `bson:"messageExpiry,omitempty"`  //Seconds new messages live for, 0 keeps them forever
 	CreatedAt        time.Time          `bson:"created_at"`
 	LastMessageAt    time.Time          `bson:"lastMessageAt"`
 }
+
+type PinnedMessage struct {
+	MessageID  primitive.ObjectID `bson:"messageID"`
+	PinnedByID string             `bson:"pinnedByID"`
+	PinnedAt   time.Time          `bson:"pinnedAt"`
+}
+
+// Kind returns the conversation type.
+// Conversations created before the type was stored are told apart by their name.
+func (c Conversation) Kind() string {
+	if c.Type != "" {
+		return c.Type
+	}
+	if c.ConversationName != nil && *c.ConversationName != "" {
+		return ConversationGroup
+	}
+	return ConversationDirect
+}
+
+func (c Conversation) IsGroup() bool {
+	return c.Kind() == ConversationGroup
+}
+
+func (c Conversation) IsChannel() bool {
+	return c.Kind() == ConversationChannel
+}
+
+func (c Conversation) IsParticipant(userID string) bool {
+	for _, p := range c.ParticipantIDs {
+		if p == userID {
+			return true
+		}
+	}
+	return false
+}
+
+// ExpiresAt returns when a message sent at sentAt disappears, or nil if it doesn't.
+func (c Conversation) ExpiresAt(sentAt time.Time) *time.Time {
+	if c.MessageExpiry <= 0 {
+		return nil
+	}
+	t := sentAt.Add(time.Duration(c.MessageExpiry) * time.Second)
+	return &t
+}
+
+// IsAdmin reports whether the user may manage the conversation.
+// Groups created before admins were tracked have none, so every participant manages them.
+func (c Conversation) IsAdmin(userID string) bool {
+	if len(c.AdminIDs) == 0 {
+		return c.IsParticipant(userID)
+	}
+	for _, a := range c.AdminIDs {
+		if a == userID {
+			return true
+		}
+	}
+	return false
+}
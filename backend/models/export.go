@@ -0,0 +1,28 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	ExportPending = "pending"
+	ExportRunning = "running"
+	ExportReady   = "ready"
+	ExportFailed  = "failed"
+)
+
+// ExportJob tracks the asynchronous build of a user's personal data archive.
+type ExportJob struct {
+	ID            primitive.ObjectID `bson:"_id,omitempty" json:"-"`
+	JobID         string             `bson:"job_id" json:"job_id"`
+	UserID        string             `bson:"user_id" json:"-"`
+	Status        string             `bson:"status" json:"status"`
+	Error         string             `bson:"error,omitempty" json:"error,omitempty"`
+	DownloadToken string             `bson:"download_token,omitempty" json:"-"` //The download link is the credential, so it is only handed out while ready
+	FilePath      string             `bson:"file_path,omitempty" json:"-"`
+	CreatedAt     time.Time          `bson:"created_at" json:"created_at"`
+	CompletedAt   *time.Time         `bson:"completed_at,omitempty" json:"completed_at,omitempty"`
+	ExpiresAt     *time.Time         `bson:"expires_at,omitempty" json:"expires_at,omitempty"` //Set once ready; the archive is deleted afterwards
+}
@@ -4,6 +4,8 @@ import "go.mongodb.org/mongo-driver/bson/primitive"
 
 type Friend struct {
 	ID             primitive.ObjectID `bson:"_id,omitempty"`
-	Username       *string            `bson:"username"`
-	FriendUsername *string            `bson:"friendusername"`
+	UserID         string             `bson:"user_id"`
+	FriendUserID   string             `bson:"friend_user_id"`
+	Username       *string            `bson:"username"`       //Display copy, kept in sync on rename
+	FriendUsername *string            `bson:"friendusername"` //Display copy, kept in sync on rename
 }
@@ -0,0 +1,34 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type Invite struct {
+	ID             primitive.ObjectID `bson:"_id,omitempty"`
+	Token          string             `bson:"token"`
+	ConversationID string             `bson:"conversationID"`
+	CreatedByID    string             `bson:"createdByID"`
+	CreatedBy      string             `bson:"createdBy"`
+	MaxUses        int                `bson:"maxUses"` //0 means unlimited
+	Uses           int                `bson:"uses"`
+	ExpiresAt      *time.Time         `bson:"expiresAt,omitempty"` //nil means the link never expires
+	Revoked        bool               `bson:"revoked"`
+	CreatedAt      time.Time          `bson:"created_at"`
+}
+
+// Usable reports whether the invite can still be redeemed at now.
+func (i Invite) Usable(now time.Time) bool {
+	if i.Revoked {
+		return false
+	}
+	if i.ExpiresAt != nil && !now.Before(*i.ExpiresAt) {
+		return false
+	}
+	if i.MaxUses > 0 && i.Uses >= i.MaxUses {
+		return false
+	}
+	return true
+}
@@ -0,0 +1,17 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Member records a user's membership of a channel.
+// Channels can grow too large to keep their members inline in Conversation.Participants.
+type Member struct {
+	ID             primitive.ObjectID `bson:"_id,omitempty"`
+	ConversationID string             `bson:"conversationID"`
+	UserID         string             `bson:"user_id"`
+	Username       string             `bson:"username"` //Display copy, kept in sync on rename
+	JoinedAt       time.Time          `bson:"joined_at"`
+}
@@ -7,9 +7,45 @@ import (
 )
 
 type Message struct {
-	ID             primitive.ObjectID `bson:"_id,omitempty"`
+	ID             primitive.ObjectID  `bson:"_id,omitempty"`
+	ConversationID string              `bson:"conversationID"`
+	SenderID       string              `bson:"senderID,omitempty"` //Empty for system announcements
+	SenderUserName string              `bson:"senderUserName"`
+	Content        string              `bson:"content"`
+	Attachments    []Attachment        `bson:"attachments,omitempty"`
+	Reactions      []ReactionCount     `bson:"-" json:"Reactions,omitempty"` //Aggregated from the reaction collection when loaded
+	ReplyTo        *ReplyPreview       `bson:"replyTo,omitempty"`            //The message being quoted, if any
+	ForwardedFrom  *ForwardedFrom      `bson:"forwardedFrom,omitempty"`      //The original of a forwarded message
+	ThreadID       *primitive.ObjectID `bson:"threadID,omitempty"`           //Root of the side thread this reply belongs to
+	Thread         *ThreadSummary      `bson:"thread,omitempty"`             //Set on a thread root once it has replies
+	Mentions       []UserRef           `bson:"mentions,omitempty"`           //Members addressed with @username
+	MentionAll     bool                `bson:"mentionAll,omitempty"`         //@all, sent by an admin
+	MentionHere    bool                `bson:"mentionHere,omitempty"`        //@here, sent by an admin
+	CreatedAt      time.Time           `bson:"created_at"`
+	ExpiresAt      *time.Time          `bson:"expiresAt,omitempty"` //Set in conversations with disappearing messages
+}
+
+// ReplyPreview is a copy of the quoted message taken when the reply is sent,
+// so clients can render the quote without fetching the original.
+type ReplyPreview struct {
+	MessageID      primitive.ObjectID `bson:"messageID"`
+	SenderID       string             `bson:"senderID,omitempty"`
+	SenderUserName string             `bson:"senderUserName"`
+	Content        string             `bson:"content"` //Truncated
+}
+
+// ForwardedFrom points a forwarded copy back at the message it was first sent as.
+// Forwarding a forward keeps pointing at that first message.
+type ForwardedFrom struct {
+	MessageID      primitive.ObjectID `bson:"messageID"`
 	ConversationID string             `bson:"conversationID"`
+	SenderID       string             `bson:"senderID"`
 	SenderUserName string             `bson:"senderUserName"`
-	Content        string             `bson:"content"`
 	CreatedAt      time.Time          `bson:"created_at"`
 }
+
+type ThreadSummary struct {
+	ReplyCount     int       `bson:"replyCount"`
+	LastReplyAt    time.Time `bson:"lastReplyAt"`
+	ParticipantIDs []string  `bson:"participantIDs"` //The root's sender and everyone who replied
+}
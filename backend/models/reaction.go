@@ -0,0 +1,24 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Reaction is one user's emoji on one message. A user may react with several different emoji.
+type Reaction struct {
+	ID             primitive.ObjectID `bson:"_id,omitempty"`
+	MessageID      primitive.ObjectID `bson:"message_id"`
+	ConversationID string             `bson:"conversationID"`
+	UserID         string             `bson:"user_id"`
+	Emoji          string             `bson:"emoji"`
+	CreatedAt      time.Time          `bson:"created_at"`
+}
+
+// ReactionCount is how a reaction is shown on a message: the emoji and how many people used it.
+type ReactionCount struct {
+	Emoji   string `json:"emoji"`
+	Count   int    `json:"count"`
+	Reacted bool   `json:"reacted,omitempty"` //Whether the user the message is sent to is one of them
+}
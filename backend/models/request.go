@@ -1,10 +1,18 @@
 package models
 
-import "go.mongodb.org/mongo-driver/bson/primitive"
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
 
 type Request struct {
-	ID     primitive.ObjectID `bson:"_id,omitempty"`
-	From   string             `bson:"from"`
-	To     string             `bson:"to"`
-	Status string             `bson:"status"`
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	FromID    string             `bson:"from_id" json:"from_id"`
+	ToID      string             `bson:"to_id" json:"to_id"`
+	From      string             `bson:"from" json:"from"`
+	To        string             `bson:"to" json:"to"`
+	Status    string             `bson:"status" json:"status"`
+	CreatedAt time.Time          `bson:"created_at,omitempty" json:"created_at"`
+	ExpiresAt *time.Time         `bson:"expiresAt,omitempty" json:"expiresAt,omitempty"` //Removed by the TTL index once reached
 }
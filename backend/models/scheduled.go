@@ -0,0 +1,31 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	ScheduledPending = "pending"
+	ScheduledSending = "sending"
+	ScheduledFailed  = "failed" //Kept so the user can see why, then edit or cancel it
+)
+
+// ScheduledMessage is a message written now and sent into an existing conversation at SendAt.
+// It is deleted once it has been sent.
+type ScheduledMessage struct {
+	ID             primitive.ObjectID `bson:"_id,omitempty" json:"-"`
+	ScheduleID     string             `bson:"schedule_id" json:"schedule_id"`
+	UserID         string             `bson:"user_id" json:"user_id"`
+	ConversationID string             `bson:"conversationID" json:"conversationID"`
+	Content        string             `bson:"content" json:"content"`
+	Attachments    []string           `bson:"attachments,omitempty" json:"attachments,omitempty"` //Upload IDs, claimed when sent
+	ReplyTo        string             `bson:"replyTo,omitempty" json:"replyTo,omitempty"`
+	ThreadID       string             `bson:"threadID,omitempty" json:"threadID,omitempty"`
+	SendAt         time.Time          `bson:"send_at" json:"send_at"`
+	Status         string             `bson:"status" json:"status"`
+	Error          string             `bson:"error,omitempty" json:"error,omitempty"`
+	CreatedAt      time.Time          `bson:"created_at" json:"created_at"`
+	UpdatedAt      time.Time          `bson:"updated_at" json:"updated_at"`
+}
@@ -1,18 +1,102 @@
 package models
 
 import (
+	"encoding/json"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 type User struct {
-	ID            primitive.ObjectID `bson:"_id,omitempty"`
-	Username      *string            `json:"username" validate:"required,min=2,max=100"`
-	Password      *string            `json:"password" validate:"required,min=6"`
-	Token         *string            `json:"token,omitempty"`
-	Refresh_token *string            `json:"refresh_token,omitempty"`
-	Created_at    time.Time          `json:"created_at"`
-	Updated_at    time.Time          `json:"updated_at"`
-	User_id       string             `json:"user_id"`
-}
\ No newline at end of file
+	ID              primitive.ObjectID `bson:"_id,omitempty"`
+	Username        *string            `json:"username" validate:"required,min=2,max=100"`
+	Password        *string            `json:"password" validate:"required,min=6"`
+	Token           *string            `json:"token,omitempty"`
+	Refresh_token   *string            `json:"refresh_token,omitempty"`
+	Created_at      time.Time          `json:"created_at"`
+	Updated_at      time.Time          `json:"updated_at"`
+	User_id         string             `json:"user_id"`
+	DisplayName     *string            `bson:"display_name,omitempty" json:"display_name,omitempty"`
+	AvatarURL       *string            `bson:"avatar_url,omitempty" json:"avatar_url,omitempty"`
+	Bio             *string            `bson:"bio,omitempty" json:"bio,omitempty"`
+	StatusText      *string            `bson:"status_text,omitempty" json:"status_text,omitempty"`
+	StatusExpiresAt *time.Time         `bson:"status_expires_at,omitempty" json:"status_expires_at,omitempty"` //nil keeps the status until it is cleared
+	DeletionAt      *time.Time         `bson:"deletion_at,omitempty" json:"-"`                                 //Set while a requested account deletion is in its grace period
+}
+
+// UserRef identifies a user by their stable user ID and carries their current username for display.
+// Usernames can change, so every stored reference to another user is keyed by ID.
+type UserRef struct {
+	ID       string `bson:"user_id" json:"user_id"`
+	Username string `bson:"username" json:"username"`
+}
+
+func (u User) Ref() UserRef {
+	ref := UserRef{ID: u.User_id}
+	if u.Username != nil {
+		ref.Username = *u.Username
+	}
+	return ref
+}
+
+//Placeholder names that can never be registered
+const (
+	AnnounceUsername = "Announce"     //Sender of system messages
+	DeletedUsername  = "Deleted User" //Replaces the name of an erased account in shared history
+)
+
+// PublicUser is the part of a user record that other users are allowed to see.
+type PublicUser struct {
+	UserID          string     `json:"user_id"`
+	Username        string     `json:"username"`
+	DisplayName     string     `json:"display_name,omitempty"`
+	AvatarURL       string     `json:"avatar_url,omitempty"`
+	Bio             string     `json:"bio,omitempty"`
+	StatusText      string     `json:"status_text,omitempty"`
+	StatusExpiresAt *time.Time `json:"status_expires_at,omitempty"`
+}
+
+// SelfUser is what users see of their own account. Credentials never leave the server.
+type SelfUser struct {
+	PublicUser
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+	DeletionAt *time.Time `json:"deletion_at,omitempty"`
+}
+
+func (u User) Self() SelfUser {
+	return SelfUser{
+		PublicUser: u.Public(),
+		CreatedAt:  u.Created_at,
+		UpdatedAt:  u.Updated_at,
+		DeletionAt: u.DeletionAt,
+	}
+}
+
+func (u User) Public() PublicUser {
+	p := PublicUser{UserID: u.User_id}
+	if u.Username != nil {
+		p.Username = *u.Username
+	}
+	if u.DisplayName != nil {
+		p.DisplayName = *u.DisplayName
+	}
+	if u.AvatarURL != nil {
+		p.AvatarURL = *u.AvatarURL
+	}
+	if u.Bio != nil {
+		p.Bio = *u.Bio
+	}
+	//An expired custom status is simply not shown
+	if u.StatusText != nil && (u.StatusExpiresAt == nil || time.Now().Before(*u.StatusExpiresAt)) {
+		p.StatusText = *u.StatusText
+		p.StatusExpiresAt = u.StatusExpiresAt
+	}
+	return p
+}
+
+// MarshalJSON keeps the password hash and tokens out of every response,
+// even if a raw User is ever serialized by mistake.
+func (u User) MarshalJSON() ([]byte, error) {
+	return json.Marshal(u.Self())
+}
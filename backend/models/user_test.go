@@ -0,0 +1,83 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var sensitiveUserFields = []string{"password", "token", "refresh_token", "Password", "Token", "Refresh_token"}
+
+func str(s string) *string {
+	return &s
+}
+
+func fullUser() User {
+	id := primitive.NewObjectID()
+	deletionAt := time.Now().Add(time.Hour)
+	return User{
+		ID:            id,
+		Username:      str("alice"),
+		Password:      str("$2a$14$hash"),
+		Token:         str("access-token"),
+		Refresh_token: str("refresh-token"),
+		Created_at:    time.Now(),
+		Updated_at:    time.Now(),
+		User_id:       id.Hex(),
+		DisplayName:   str("Alice"),
+		Bio:           str("hi"),
+		DeletionAt:    &deletionAt,
+	}
+}
+
+func assertNoSensitiveFields(t *testing.T, name string, v interface{}) {
+	t.Helper()
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatalf("%s: marshal: %v", name, err)
+	}
+
+	var fields map[string]interface{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		t.Fatalf("%s: unmarshal: %v", name, err)
+	}
+
+	for _, key := range sensitiveUserFields {
+		if _, found := fields[key]; found {
+			t.Errorf("%s exposes %q: %s", name, key, data)
+		}
+	}
+}
+
+func TestUserViewsHideCredentials(t *testing.T) {
+	u := fullUser()
+
+	assertNoSensitiveFields(t, "Self", u.Self())
+	assertNoSensitiveFields(t, "Public", u.Public())
+	assertNoSensitiveFields(t, "User", u)
+	assertNoSensitiveFields(t, "*User", &u)
+}
+
+func TestPublicHidesAccountDetails(t *testing.T) {
+	data, err := json.Marshal(fullUser().Public())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var fields map[string]interface{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, key := range []string{"deletion_at", "created_at", "updated_at"} {
+		if _, found := fields[key]; found {
+			t.Errorf("Public exposes %q: %s", key, data)
+		}
+	}
+	if fields["username"] != "alice" {
+		t.Errorf("username = %v, want alice", fields["username"])
+	}
+}
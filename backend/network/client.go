@@ -3,23 +3,49 @@ package network
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"log"
 	"sort"
+	"sync"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/gorilla/websocket"
 	"github.com/shjung-dev/ChatApplication/backend/config"
+	"github.com/shjung-dev/ChatApplication/backend/helpers"
 	"github.com/shjung-dev/ChatApplication/backend/models"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
 )
 
 type Client struct {
 	Socket   *websocket.Conn
 	Receive  chan []byte
 	Room     *Room
+	UserID   string
 	Username string
+
+	sendMu sync.Mutex //Guards closed so nothing is sent on Receive after Read closes it
+	closed bool
+}
+
+// send queues payload for the socket without blocking. It returns false if the client
+// is gone or its buffer is full, in which case the payload is dropped.
+func (c *Client) send(payload []byte) bool {
+	c.sendMu.Lock()
+	defer c.sendMu.Unlock()
+
+	if c.closed {
+		return false
+	}
+
+	select {
+	case c.Receive <- payload:
+		return true
+	default:
+		return false
+	}
 }
 
 type WSMessage struct {
@@ -29,6 +55,11 @@ type WSMessage struct {
 	GroupName      string   `json:"groupName"`
 	Members        []string `json:"members"`
 	MessageContent string   `json:"messageContent"`
+	Attachments    []string `json:"attachments"` //IDs returned by the upload endpoint
+	MessageID      string   `json:"messageID"`   //Message to react to, pin or forward
+	Emoji          string   `json:"emoji"`
+	ReplyTo        string   `json:"replyTo"`  //ID of a message to quote
+	ThreadID       string   `json:"threadID"` //ID of the root message to reply to in its side thread
 }
 
 type OutgoingMessage struct {
@@ -42,7 +73,13 @@ func (c *Client) Read() {
 
 	defer func() {
 		c.Room.Leave <- c
+		removeOnlineClient(c)
+
+		c.sendMu.Lock()
+		c.closed = true
 		close(c.Receive)
+		c.sendMu.Unlock()
+
 		c.Socket.Close()
 	}()
 
@@ -58,40 +95,14 @@ func (c *Client) Read() {
 			continue
 		}
 
-		from := c.Username
-
 		switch msg.Type {
 		case "friend_request":
-			requestCollection := config.OpenCollection("request")
-			_, err := requestCollection.InsertOne(context.Background(), bson.M{
-				"from":   from,
-				"to":     msg.To,
-				"status": "pending",
-			})
-			if err != nil {
-				log.Println("Failed to insert friend request:", err)
-				continue
-			}
-			//Check if the recipient client is also online
-			toClient, online := onlineClients[msg.To]
-			if online {
-				//Client is online too so immediately send over websocket
-				jsMsg, err := json.Marshal(OutgoingMessage{
-					From: from,
-					To:   msg.To,
-					Type: msg.Type,
-				})
-				if err != nil {
-					log.Println("Failed to marshal message:", err)
-					continue
-				}
-				toClient.Receive <- jsMsg
-				continue
-			}
+			c.sendFriendRequest(msg)
+			continue
 		case "friend_list_update":
 			friendCollection := config.OpenCollection("friend")
 			cursor, err := friendCollection.Find(context.Background(), bson.M{
-				"username": from,
+				"user_id": c.UserID,
 			})
 
 			if err != nil {
@@ -112,148 +123,243 @@ func (c *Client) Read() {
 				"type":    "friend_list_update",
 				"friends": friends,
 			})
-			c.Receive <- response
+			c.send(response)
+			continue
+		case "outgoing_requests":
+			c.LoadOutgoingFriendRequests()
+			continue
+		case "react", "unreact":
+			c.react(msg)
+			continue
+		case "pin", "unpin":
+			c.pin(msg)
+			continue
+		case "forward_message":
+			c.forwardMessage(msg)
 			continue
 		case "message":
-			currentUser := c.Username
-			announceMessage := false
-
-			//Check if this convo exists
-			convoCollection := config.OpenCollection("conversation")
-			var convo models.Conversation
-			err := convoCollection.FindOne(context.Background(), bson.M{"conversationID": msg.ConvoID}).Decode(&convo)
-
-			if err != nil {
-				//This convo whether it is 1to1 or group doesn't exist
-
-				announceMessage = true
-				convoID := uuid.NewString()
-				participants := append(msg.Members, currentUser)
-				participants = unique(participants)
-
-				/*Update Convo in database
-				-> This convo will now persist for all users
-				*/
-				convo = models.Conversation{
-					ID:               primitive.NewObjectID(),
-					ConversationID:   convoID,
-					ConversationName: &msg.GroupName, //If it is 1 to 1 chat, this will be null
-					Participants:     participants,
-					CreatedAt:        time.Now(),
-				}
-				_, insertErr1 := convoCollection.InsertOne(context.Background(), convo)
-				if insertErr1 != nil {
-					log.Println("Failed to insert convo:", insertErr1.Error())
-					continue
-				}
-			}
+			c.handleMessage(msg)
+			continue
+		}
+	}
+}
 
-			//This convo already exists OR Finished adding new convo
-			//We still need to update Convo whether it is a new or existing because received new message
-			filter := bson.M{
-				"conversationID": convo.ConversationID,
-			}
+// startConversation creates the 1-to-1 or group chat a first message is addressed to and posts it.
+// A new group opens with an announcement instead of a message from its creator.
+func (c *Client) startConversation(ctx context.Context, msg WSMessage) {
+	currentUser := c.Ref()
 
-			update := bson.M{
-				"$set": bson.M{
-					"lastMessageAt": time.Now(),
-				},
-			}
+	members, err := helpers.LookupUsers(ctx, msg.Members)
+	if err != nil {
+		log.Println("Failed to look up members:", err.Error())
+		return
+	}
 
-			result, err := convoCollection.UpdateOne(context.Background(), filter, update)
+	participantIDs := []string{currentUser.ID}
+	participants := []string{currentUser.Username}
+	for _, m := range members {
+		if m.ID != currentUser.ID {
+			participantIDs = append(participantIDs, m.ID)
+			participants = append(participants, m.Username)
+		}
+	}
 
-			if err != nil {
-				log.Println("Failed to update convo:", err.Error())
-				continue
-			}
+	//Nobody can be pulled into a new conversation with someone they blocked or were blocked by
+	blocked, err := blockedAmong(ctx, currentUser.ID, participantIDs)
+	if err != nil {
+		log.Println("Failed to check blocks:", err.Error())
+		return
+	}
+	if blocked {
+		c.sendError(msg.Type, "You cannot start a conversation with this user")
+		return
+	}
 
-			if result.MatchedCount == 0 {
-				log.Println("Convo is not found")
-				return
-			}
+	/*Update Convo in database
+	-> This convo will now persist for all users
+	*/
+	convo := models.Conversation{
+		ID:               primitive.NewObjectID(),
+		ConversationID:   uuid.NewString(),
+		ConversationName: &msg.GroupName, //If it is 1 to 1 chat, this will be null
+		ParticipantIDs:   participantIDs,
+		Participants:     participants,
+		CreatedAt:        time.Now(),
+	}
+	convo.Type = models.ConversationDirect
+	if msg.GroupName != "" {
+		//The creator manages the group (invite links etc.)
+		convo.Type = models.ConversationGroup
+		convo.CreatedByID = currentUser.ID
+		convo.CreatedBy = currentUser.Username
+		convo.AdminIDs = []string{currentUser.ID}
+		convo.Admins = []string{currentUser.Username}
+	}
 
-			//Update Message
-			messageCollection := config.OpenCollection("message")
-			var m models.Message
-
-			//Differentiate between 1-to-1 and group chat
-			if msg.GroupName == "" {
-				//This is 1-to-1
-				m = models.Message{
-					ID:             primitive.NewObjectID(),
-					ConversationID: convo.ConversationID,
-					SenderUserName: currentUser,
-					Content:        msg.MessageContent,
-					CreatedAt:      time.Now(),
-				}
-			} else {
-				//This is group chat
-
-				//Check whether this is a newly created group chat
-				if announceMessage { //this will hold true if the convo is newly created
-					m = models.Message{
-						ID:             primitive.NewObjectID(),
-						ConversationID: convo.ConversationID,
-						SenderUserName: "Announce", //This will be an announcement that this group chat is created to all the participants
-						Content:        msg.MessageContent,
-						CreatedAt:      time.Now(),
-					}
-				} else {
-					//This is already an existing group chat
-					m = models.Message{
-						ID:             primitive.NewObjectID(),
-						ConversationID: convo.ConversationID,
-						SenderUserName: currentUser,
-						Content:        msg.MessageContent,
-						CreatedAt:      time.Now(),
-					}
-				}
-			}
-			_, insertErr := messageCollection.InsertOne(context.Background(), m)
-			if insertErr != nil {
-				log.Println("Failed to insert message:", insertErr.Error())
-				continue
-			}
+	convoCollection := config.OpenCollection("conversation")
+	if _, err := convoCollection.InsertOne(ctx, convo); err != nil {
+		log.Println("Failed to insert convo:", err.Error())
+		return
+	}
 
-			//Send back payload to all the participants of this convo
-			response, err := json.Marshal(map[string]interface{}{
-				"type":    "message",
-				"convo":   convo,
-				"message": m,
-			})
-			if err != nil {
-				log.Println("Failed to marshal message:", err)
-				continue
-			}
+	m := models.Message{
+		ID:             primitive.NewObjectID(),
+		ConversationID: convo.ConversationID,
+		SenderID:       currentUser.ID,
+		SenderUserName: currentUser.Username,
+		Content:        msg.MessageContent,
+		CreatedAt:      time.Now(),
+	}
+	if convo.Type == models.ConversationGroup {
+		//This will be an announcement that this group chat is created to all the participants
+		m.SenderID = ""
+		m.SenderUserName = models.AnnounceUsername
+	}
 
-			for _, p := range convo.Participants {
-				//Check if the participant is online
-				toClient, online := onlineClients[p]
-				if online {
-					//Immediately send the payload
-					toClient.Receive <- response
-				}
-			}
-			continue
-		}
+	if err := publishMessage(ctx, convo, m); err != nil {
+		log.Println("Failed to insert message:", err.Error())
 	}
 }
 
 /*-----------------------------------------------------------------------------------------------*/
 
-func unique(input []string) []string {
-	seen := make(map[string]struct{})
-	result := make([]string, 0, len(input))
+func (c *Client) sendFriendRequest(msg WSMessage) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
 
-	for _, v := range input {
-		if _, ok := seen[v]; ok {
-			continue
-		}
-		seen[v] = struct{}{}
-		result = append(result, v)
+	from := c.Ref()
+
+	if msg.To == "" {
+		c.sendError(msg.Type, "to is required")
+		return
+	}
+
+	if msg.To == from.Username {
+		c.sendError(msg.Type, "You cannot send a friend request to yourself")
+		return
+	}
+
+	to, err := helpers.LookupUser(ctx, msg.To)
+	if err == mongo.ErrNoDocuments {
+		c.sendError(msg.Type, "User not found")
+		return
+	}
+	if err != nil {
+		log.Println("Failed to look up user:", err)
+		return
+	}
+
+	//Someone who blocked us must look exactly like someone who doesn't exist
+	blockedByThem, err := helpers.HasBlocked(ctx, to.ID, from.ID)
+	if err != nil {
+		log.Println("Failed to check blocks:", err)
+		return
+	}
+	if blockedByThem {
+		c.sendError(msg.Type, "User not found")
+		return
+	}
+
+	blockedByUs, err := helpers.HasBlocked(ctx, from.ID, to.ID)
+	if err != nil {
+		log.Println("Failed to check blocks:", err)
+		return
+	}
+	if blockedByUs {
+		c.sendError(msg.Type, "Unblock this user before sending a friend request")
+		return
+	}
+
+	alreadyFriends, err := helpers.AreFriends(ctx, from.ID, to.ID)
+	if err != nil {
+		log.Println("Failed to check friendship:", err)
+		return
+	}
+	if alreadyFriends {
+		c.sendError(msg.Type, "You are already friends")
+		return
+	}
+
+	requestCollection := config.OpenCollection("request")
+
+	count, err := requestCollection.CountDocuments(ctx, bson.M{
+		"from_id": from.ID,
+		"to_id":   to.ID,
+		"status":  "pending",
+	})
+	if err != nil {
+		log.Println("Failed to check pending requests:", err)
+		return
+	}
+	if count > 0 {
+		c.sendError(msg.Type, "Friend request already sent")
+		return
 	}
 
-	return result
+	switch err := helpers.CheckFriendRequestAllowed(ctx, from.ID, to.ID); {
+	case errors.Is(err, helpers.ErrRequestCooldown), errors.Is(err, helpers.ErrDailyRequestLimit):
+		c.sendError(msg.Type, err.Error())
+		return
+	case err != nil:
+		log.Println("Failed to check request limits:", err)
+		return
+	}
+
+	//They already asked us -> sending one back is the same as accepting theirs
+	accepted, err := helpers.AcceptFriendRequest(ctx, to, from)
+	if err != nil {
+		log.Println("Failed to accept reverse request:", err)
+		return
+	}
+	if accepted {
+		NotifyUser(to.ID, OutgoingMessage{
+			From: from.Username,
+			To:   to.Username,
+			Type: "friend_accepted",
+		})
+		NotifyUser(from.ID, OutgoingMessage{
+			From: to.Username,
+			To:   from.Username,
+			Type: "friend_accepted",
+		})
+		return
+	}
+
+	err = helpers.InsertFriendRequest(ctx, from, to)
+	if mongo.IsDuplicateKeyError(err) {
+		//Lost a race against an identical request
+		c.sendError(msg.Type, "Friend request already sent")
+		return
+	}
+	if err != nil {
+		log.Println("Failed to insert friend request:", err)
+		return
+	}
+
+	//If the recipient is also online, immediately send over websocket
+	NotifyUser(to.ID, OutgoingMessage{
+		From: from.Username,
+		To:   to.Username,
+		Type: msg.Type,
+	})
+}
+
+func (c *Client) Ref() models.UserRef {
+	return models.UserRef{ID: c.UserID, Username: c.Username}
+}
+
+// sendError reports a rejected websocket request back to the sender only.
+func (c *Client) sendError(requestType string, message string) {
+	response, err := json.Marshal(map[string]interface{}{
+		"type":        "error",
+		"requestType": requestType,
+		"error":       message,
+	})
+	if err != nil {
+		log.Println("Failed to marshal message:", err)
+		return
+	}
+	c.send(response)
 }
 
 /*-----------------------------------------------------------------------------------------------*/
@@ -262,9 +368,18 @@ func unique(input []string) []string {
 
 func (c *Client) LoadAllMessage() {
 	//Get all related convo to this client
+	channelIDs, err := helpers.ChannelIDsForMember(context.Background(), c.UserID)
+	if err != nil {
+		log.Println("Failed to fetch channel memberships:", err)
+		return
+	}
+
 	convoCollection := config.OpenCollection("conversation")
 	filter := bson.M{
-		"participants": c.Username,
+		"$or": bson.A{
+			bson.M{"participantIDs": c.UserID},
+			bson.M{"conversationID": bson.M{"$in": channelIDs}},
+		},
 	}
 	ConvoCursor, err := convoCollection.Find(context.Background(), filter)
 	
@@ -283,8 +398,17 @@ func (c *Client) LoadAllMessage() {
 
 	messagesByConversationID := make(map[string][]models.Message)
 
+	convoIDs := make([]string, 0, len(convos))
+	for _, convo := range convos {
+		convoIDs = append(convoIDs, convo.ConversationID)
+	}
+
 	messageCollection := config.OpenCollection("message")
-	MessageCursor, err := messageCollection.Find(context.Background(), bson.M{})
+	MessageCursor, err := messageCollection.Find(context.Background(), bson.M{
+		"conversationID": bson.M{"$in": convoIDs},
+		"threadID":       bson.M{"$exists": false}, //Thread replies are loaded with their thread
+		"expiresAt":      helpers.Unexpired(),
+	})
 	if err != nil {
 		log.Println("Error retrieving all the message documents")
 		return
@@ -292,7 +416,7 @@ func (c *Client) LoadAllMessage() {
 
 	defer MessageCursor.Close(context.Background())
 
-	//Group ALL the messages by its unique ConversationID
+	//Group the messages by its unique ConversationID
 	for MessageCursor.Next(context.Background()) {
 		var message models.Message
 		if err := MessageCursor.Decode(&message); err != nil {
@@ -305,8 +429,13 @@ func (c *Client) LoadAllMessage() {
 	//Sort Messages with oldest message -> latest message for each ConversationID
 	for _, msgs := range messagesByConversationID {
 		sort.Slice(msgs, func(i, j int) bool {
-			return msgs[i].CreatedAt.Before(msgs[i].CreatedAt)
+			return msgs[i].CreatedAt.Before(msgs[j].CreatedAt)
 		})
+
+		if err := helpers.AttachReactions(context.Background(), msgs, c.UserID); err != nil {
+			log.Println("Failed to fetch reactions:", err)
+			return
+		}
 	}
 
 	type ConvoAndMessagesItem struct {
@@ -334,7 +463,7 @@ func (c *Client) LoadAllMessage() {
 		log.Println("Failed to marshal message:", err)
 		return
 	}
-	c.Receive <- payload
+	c.send(payload)
 }
 
 /*-----------------------------------------------------------------------------------------------*/
@@ -342,7 +471,7 @@ func (c *Client) LoadAllMessage() {
 func (c *Client) LoadAllFriends() {
 	friendCollection := config.OpenCollection("friend")
 	cursor, err := friendCollection.Find(context.Background(), bson.M{
-		"username": c.Username,
+		"user_id": c.UserID,
 	})
 
 	if err != nil {
@@ -363,13 +492,13 @@ func (c *Client) LoadAllFriends() {
 		"type":    "friend_list_update",
 		"friends": friends,
 	})
-	c.Receive <- response
+	c.send(response)
 }
 
 func (c *Client) ReceivePendingFriendRequest() {
 	requestCollection := config.OpenCollection("request")
 	cursor, err := requestCollection.Find(context.Background(), bson.M{
-		"to":     c.Username,
+		"to_id":  c.UserID,
 		"status": "pending",
 	})
 	if err != nil {
@@ -396,14 +525,34 @@ func (c *Client) ReceivePendingFriendRequest() {
 			log.Println("Failed to marshal message:", err)
 			continue
 		}
-		c.Receive <- jsMsg
+		c.send(jsMsg)
+	}
+}
+
+func (c *Client) LoadOutgoingFriendRequests() {
+	requests, err := helpers.OutgoingRequests(context.Background(), c.UserID)
+	if err != nil {
+		log.Println("Failed to fetch outgoing requests:", err)
+		return
+	}
+
+	response, err := json.Marshal(map[string]interface{}{
+		"type":     "outgoing_requests",
+		"requests": requests,
+	})
+	if err != nil {
+		log.Println("Failed to marshal message:", err)
+		return
 	}
+	c.send(response)
 }
 
 func (c *Client) Write() {
 	for msg := range c.Receive {
 		if err := c.Socket.WriteMessage(websocket.TextMessage, msg); err != nil {
 			log.Println("Write error:", err)
+			//Closing the socket ends Read, which unregisters the client
+			c.Socket.Close()
 			return
 		}
 	}
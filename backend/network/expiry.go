@@ -0,0 +1,59 @@
+package network
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/shjung-dev/ChatApplication/backend/config"
+	"github.com/shjung-dev/ChatApplication/backend/helpers"
+	"github.com/shjung-dev/ChatApplication/backend/models"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// StartMessageExpirySweeper deletes disappearing messages once they expire and tells
+// the online participants so their clients purge them too.
+func StartMessageExpirySweeper(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			sweepExpiredMessages()
+		}
+	}()
+}
+
+func sweepExpiredMessages() {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	expired, err := helpers.DeleteExpiredMessages(ctx)
+	if err != nil {
+		log.Println("Failed to delete expired messages:", err)
+		return
+	}
+
+	convoCollection := config.OpenCollection("conversation")
+	for convoID, messageIDs := range expired {
+		var convo models.Conversation
+
+		if err := convoCollection.FindOne(ctx, bson.M{"conversationID": convoID}).Decode(&convo); err != nil {
+			log.Println("Failed to fetch convo:", err)
+			continue
+		}
+
+		response, err := json.Marshal(map[string]interface{}{
+			"type":       "message_expired",
+			"convoID":    convoID,
+			"messageIDs": messageIDs,
+		})
+		if err != nil {
+			log.Println("Failed to marshal message:", err)
+			continue
+		}
+
+		BroadcastToConversation(convo, response)
+	}
+}
@@ -0,0 +1,104 @@
+package network
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/shjung-dev/ChatApplication/backend/config"
+	"github.com/shjung-dev/ChatApplication/backend/helpers"
+	"github.com/shjung-dev/ChatApplication/backend/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// forwardMessage is the "forward_message" websocket event: it copies MessageID into the
+// conversation ConvoID. The user must be able to read the original and post into the target.
+func (c *Client) forwardMessage(msg WSMessage) {
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	original, _, ok := c.findMessage(ctx, msg)
+	if !ok {
+		return
+	}
+
+	var target models.Conversation
+
+	convoCollection := config.OpenCollection("conversation")
+	if err := convoCollection.FindOne(ctx, bson.M{"conversationID": msg.ConvoID}).Decode(&target); err != nil {
+		c.sendError(msg.Type, "Conversation not found")
+		return
+	}
+
+	if _, err := forward(ctx, c.Ref(), original, target); err != nil {
+		if reason, ok := refusal(err); ok {
+			c.sendError(msg.Type, reason)
+			return
+		}
+		log.Println("Failed to forward message:", err)
+	}
+}
+
+// forward posts a copy of original from sender into convo, with its own copies of the
+// attachments. Mentions aren't carried over since they named members of the original conversation.
+func forward(ctx context.Context, sender models.UserRef, original models.Message, convo models.Conversation) (models.Message, error) {
+	var m models.Message
+
+	if original.SenderID == "" {
+		return m, refused("Announcements cannot be forwarded")
+	}
+
+	if err := checkCanPost(ctx, sender, convo); err != nil {
+		return m, err
+	}
+
+	forwardedFrom := original.ForwardedFrom
+	if forwardedFrom == nil {
+		forwardedFrom = &models.ForwardedFrom{
+			MessageID:      original.ID,
+			ConversationID: original.ConversationID,
+			SenderID:       original.SenderID,
+			SenderUserName: original.SenderUserName,
+			CreatedAt:      original.CreatedAt,
+		}
+	}
+
+	//Images that are still processing, or failed to, aren't shown to anyone but their uploader
+	var attachments []models.Attachment
+	for _, a := range original.Attachments {
+		if a.Viewable() {
+			attachments = append(attachments, a)
+		}
+	}
+
+	if original.Content == "" && len(attachments) == 0 {
+		return m, refused("This message has nothing to forward yet")
+	}
+
+	now := time.Now()
+	m = models.Message{
+		ID:             primitive.NewObjectID(),
+		ConversationID: convo.ConversationID,
+		SenderID:       sender.ID,
+		SenderUserName: sender.Username,
+		Content:        original.Content,
+		ForwardedFrom:  forwardedFrom,
+		CreatedAt:      now,
+		ExpiresAt:      convo.ExpiresAt(now),
+	}
+
+	var err error
+	m.Attachments, err = helpers.CopyAttachments(ctx, attachments, convo.ConversationID, sender.ID, m.ID)
+	if err != nil {
+		return m, err
+	}
+
+	if err := publishMessage(ctx, convo, m); err != nil {
+		if cleanupErr := helpers.DeleteMessageAttachments(ctx, []primitive.ObjectID{m.ID}); cleanupErr != nil {
+			log.Println("Failed to delete forwarded attachments:", cleanupErr)
+		}
+		return m, err
+	}
+	return m, nil
+}
@@ -0,0 +1,114 @@
+package network
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/shjung-dev/ChatApplication/backend/config"
+	"github.com/shjung-dev/ChatApplication/backend/helpers"
+	"github.com/shjung-dev/ChatApplication/backend/models"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+var mediaQueue = make(chan models.Attachment, 256)
+
+// StartMediaWorkers starts the goroutines that process sent images and picks up
+// any images that were still queued when the server last stopped.
+func StartMediaWorkers(workers int) {
+	if workers < 1 {
+		workers = 1
+	}
+	for i := 0; i < workers; i++ {
+		go func() {
+			for attachment := range mediaQueue {
+				processMedia(attachment)
+			}
+		}()
+	}
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		defer cancel()
+
+		pending, err := helpers.PendingImageAttachments(ctx)
+		if err != nil {
+			log.Println("Failed to fetch pending images:", err)
+			return
+		}
+		QueueMedia(pending)
+	}()
+}
+
+// QueueMedia hands the attachments that still need processing to the media workers.
+func QueueMedia(attachments []models.Attachment) {
+	for _, a := range attachments {
+		if a.Status != models.AttachmentProcessing {
+			continue
+		}
+
+		select {
+		case mediaQueue <- a:
+		default:
+			//Don't hold up the sender's read loop when the workers are busy
+			go func(a models.Attachment) { mediaQueue <- a }(a)
+		}
+	}
+}
+
+func processMedia(attachment models.Attachment) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+
+	//A malformed image must not take the server down, or it would crash again on every
+	//restart when the pending images are queued back up
+	defer func() {
+		if r := recover(); r != nil {
+			log.Println("Panic while processing attachment", attachment.AttachmentID+":", r)
+			helpers.FailAttachment(ctx, attachment)
+			if err := publishMessageUpdated(ctx, attachment); err != nil {
+				log.Println("Failed to publish processed attachment", attachment.AttachmentID+":", err)
+			}
+		}
+	}()
+
+	if _, err := helpers.ProcessAttachment(ctx, attachment); err != nil {
+		log.Println("Failed to process attachment", attachment.AttachmentID+":", err)
+	}
+
+	//Publish failures too so clients stop showing a spinner
+	if err := publishMessageUpdated(ctx, attachment); err != nil {
+		log.Println("Failed to publish processed attachment", attachment.AttachmentID+":", err)
+	}
+}
+
+// publishMessageUpdated pushes the message carrying attachment, as now stored, to the conversation.
+func publishMessageUpdated(ctx context.Context, attachment models.Attachment) error {
+	var m models.Message
+
+	messageCollection := config.OpenCollection("message")
+	if err := messageCollection.FindOne(ctx, bson.M{"_id": attachment.MessageID}).Decode(&m); err != nil {
+		return err
+	}
+
+	//Reactions are left out; clients keep the ones they already have
+	var convo models.Conversation
+
+	convoCollection := config.OpenCollection("conversation")
+	if err := convoCollection.FindOne(ctx, bson.M{"conversationID": m.ConversationID}).Decode(&convo); err != nil {
+		return err
+	}
+
+	response, err := json.Marshal(map[string]interface{}{
+		"type":    "message_updated",
+		"convo":   convo,
+		"message": m,
+	})
+	if err != nil {
+		return err
+	}
+
+	BroadcastToConversation(convo, response)
+	return nil
+}
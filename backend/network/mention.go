@@ -0,0 +1,107 @@
+package network
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/shjung-dev/ChatApplication/backend/helpers"
+	"github.com/shjung-dev/ChatApplication/backend/models"
+)
+
+// addMentions records who sender's message addresses. Only admins may use @all and @here.
+// A failed lookup drops the mentions rather than the message.
+func addMentions(ctx context.Context, sender models.UserRef, m *models.Message, convo models.Conversation) {
+	mentions := helpers.ParseMentions(m.Content)
+
+	resolved, err := helpers.ResolveMentions(ctx, convo, mentions.Usernames)
+	if err != nil {
+		log.Println("Failed to resolve mentions:", err)
+		return
+	}
+
+	if len(resolved) > 0 {
+		m.Mentions = resolved
+	}
+	if convo.IsAdmin(sender.ID) {
+		m.MentionAll = mentions.All
+		m.MentionHere = mentions.Here
+	}
+}
+
+// notifyMentions sends a separate "mention" event to every online user m addresses, on top
+// of the regular message event. It is meant to get through even when the conversation
+// itself is muted, so muting should only ever apply to "message".
+func notifyMentions(convo models.Conversation, m models.Message) {
+	if len(m.Mentions) == 0 && !m.MentionAll && !m.MentionHere {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var targets []string
+	if m.MentionAll || m.MentionHere {
+		//Without push notifications only online members can be reached, so both mean the same here
+		online, err := onlineMembers(ctx, convo)
+		if err != nil {
+			log.Println("Failed to fetch online members:", err)
+			return
+		}
+		targets = online
+	} else {
+		for _, u := range m.Mentions {
+			targets = append(targets, u.ID)
+		}
+	}
+
+	//People who blocked the sender still see the message in a shared group, but aren't pinged
+	blocked, err := helpers.BlockedUserIDs(ctx, m.SenderID)
+	if err != nil {
+		log.Println("Failed to check blocks:", err)
+		return
+	}
+	skip := map[string]bool{m.SenderID: true}
+	for _, b := range blocked {
+		skip[b] = true
+	}
+
+	response, err := json.Marshal(map[string]interface{}{
+		"type":    "mention",
+		"convoID": convo.ConversationID,
+		"message": m,
+	})
+	if err != nil {
+		log.Println("Failed to marshal message:", err)
+		return
+	}
+
+	for _, userID := range helpers.Unique(targets) {
+		if !skip[userID] {
+			SendToUser(userID, response)
+		}
+	}
+}
+
+// onlineMembers returns the members of convo that currently have a socket open.
+func onlineMembers(ctx context.Context, convo models.Conversation) ([]string, error) {
+	onlineMu.Lock()
+	online := make([]string, 0, len(onlineClients))
+	for userID := range onlineClients {
+		online = append(online, userID)
+	}
+	onlineMu.Unlock()
+
+	if convo.IsChannel() {
+		return helpers.FilterChannelMembers(ctx, convo.ConversationID, online)
+	}
+
+	members := []string{}
+	for _, userID := range online {
+		if convo.IsParticipant(userID) {
+			members = append(members, userID)
+		}
+	}
+	return members, nil
+}
@@ -0,0 +1,131 @@
+package network
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/shjung-dev/ChatApplication/backend/config"
+	"github.com/shjung-dev/ChatApplication/backend/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// removeOnlineClient drops c from the online tracker unless the same user has
+// already reconnected with a newer socket.
+func removeOnlineClient(c *Client) {
+	onlineMu.Lock()
+	defer onlineMu.Unlock()
+
+	if onlineClients[c.UserID] == c {
+		delete(onlineClients, c.UserID)
+	}
+}
+
+// Disconnect closes the user's live socket, if any. The client's read loop then cleans up as usual.
+func Disconnect(userID string) {
+	onlineMu.Lock()
+	defer onlineMu.Unlock()
+
+	if client, online := onlineClients[userID]; online {
+		client.Socket.Close()
+	}
+}
+
+// SendToUser delivers an encoded payload to the user if they are online.
+// A client that can't keep up is disconnected rather than holding up everyone else.
+func SendToUser(userID string, payload []byte) bool {
+	onlineMu.Lock()
+	toClient, online := onlineClients[userID]
+	onlineMu.Unlock()
+
+	if !online {
+		return false
+	}
+
+	if !toClient.send(payload) {
+		log.Println("Dropping message for", userID, "and disconnecting slow client")
+		toClient.Socket.Close()
+		return false
+	}
+	return true
+}
+
+// NotifyUser marshals event and delivers it to the user if they are online.
+func NotifyUser(userID string, event interface{}) {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		log.Println("Failed to marshal message:", err)
+		return
+	}
+	SendToUser(userID, payload)
+}
+
+// BroadcastToConversation delivers an encoded payload to every online participant of convo.
+func BroadcastToConversation(convo models.Conversation, payload []byte) {
+	if !convo.IsChannel() {
+		for _, p := range convo.ParticipantIDs {
+			SendToUser(p, payload)
+		}
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	//Channels can be huge, so only look up the members that are actually online
+	members, err := onlineMembers(ctx, convo)
+	if err != nil {
+		log.Println("Failed to fetch channel members:", err)
+		return
+	}
+
+	for _, m := range members {
+		SendToUser(m, payload)
+	}
+}
+
+// PostAnnouncement stores a system message in convo and pushes it to the
+// online participants the same way a regular chat message is delivered.
+func PostAnnouncement(convo models.Conversation, content string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	now := time.Now()
+	m := models.Message{
+		ID:             primitive.NewObjectID(),
+		ConversationID: convo.ConversationID,
+		SenderUserName: models.AnnounceUsername,
+		Content:        content,
+		CreatedAt:      now,
+		ExpiresAt:      convo.ExpiresAt(now),
+	}
+
+	messageCollection := config.OpenCollection("message")
+	if _, err := messageCollection.InsertOne(ctx, m); err != nil {
+		return err
+	}
+
+	convoCollection := config.OpenCollection("conversation")
+	_, err := convoCollection.UpdateOne(ctx,
+		bson.M{"conversationID": convo.ConversationID},
+		bson.M{"$set": bson.M{"lastMessageAt": m.CreatedAt}},
+	)
+	if err != nil {
+		return err
+	}
+	convo.LastMessageAt = m.CreatedAt
+
+	response, err := json.Marshal(map[string]interface{}{
+		"type":    "message",
+		"convo":   convo,
+		"message": m,
+	})
+	if err != nil {
+		return err
+	}
+
+	BroadcastToConversation(convo, response)
+	return nil
+}
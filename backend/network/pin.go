@@ -0,0 +1,70 @@
+package network
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/shjung-dev/ChatApplication/backend/helpers"
+)
+
+// pin handles both "pin" and "unpin" and sends the conversation its new pinned list.
+func (c *Client) pin(msg WSMessage) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	m, convo, ok := c.findMessage(ctx, msg)
+	if !ok {
+		return
+	}
+
+	//Either side of a 1-to-1 chat counts as an admin
+	if !convo.IsAdmin(c.UserID) {
+		c.sendError(msg.Type, "Only admins can pin messages in this conversation")
+		return
+	}
+
+	var changed bool
+	var err error
+	if msg.Type == "pin" {
+		changed, err = helpers.PinMessage(ctx, m, c.UserID)
+	} else {
+		changed, err = helpers.UnpinMessage(ctx, convo.ConversationID, m.ID)
+	}
+
+	switch {
+	case errors.Is(err, helpers.ErrTooManyPins):
+		c.sendError(msg.Type, err.Error())
+		return
+	case err != nil:
+		log.Println("Failed to update pins:", err)
+		return
+	}
+
+	if !changed {
+		return
+	}
+
+	pins, err := helpers.PinnedMessages(ctx, convo.ConversationID)
+	if err != nil {
+		log.Println("Failed to fetch pins:", err)
+		return
+	}
+
+	response, err := json.Marshal(map[string]interface{}{
+		"type":      "pins_updated",
+		"convoID":   convo.ConversationID,
+		"messageID": m.ID,
+		"user_id":   c.UserID,
+		"action":    msg.Type,
+		"pins":      pins,
+	})
+	if err != nil {
+		log.Println("Failed to marshal message:", err)
+		return
+	}
+
+	BroadcastToConversation(convo, response)
+}
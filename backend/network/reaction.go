@@ -0,0 +1,127 @@
+package network
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/shjung-dev/ChatApplication/backend/config"
+	"github.com/shjung-dev/ChatApplication/backend/helpers"
+	"github.com/shjung-dev/ChatApplication/backend/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// react handles both "react" and "unreact" and tells the conversation about the new counts.
+func (c *Client) react(msg WSMessage) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	m, convo, ok := c.findMessage(ctx, msg)
+	if !ok {
+		return
+	}
+
+	if convo.Kind() == models.ConversationDirect {
+		blocked, err := blockedAmong(ctx, c.UserID, convo.ParticipantIDs)
+		if err != nil {
+			log.Println("Failed to check blocks:", err)
+			return
+		}
+		if blocked {
+			c.sendError(msg.Type, "You cannot react in this chat")
+			return
+		}
+	}
+
+	var changed bool
+	var err error
+	if msg.Type == "react" {
+		changed, err = helpers.AddReaction(ctx, m, c.UserID, msg.Emoji)
+	} else {
+		changed, err = helpers.RemoveReaction(ctx, m.ID, c.UserID, msg.Emoji)
+	}
+
+	switch {
+	case errors.Is(err, helpers.ErrInvalidEmoji), errors.Is(err, helpers.ErrTooManyReactionKinds):
+		c.sendError(msg.Type, err.Error())
+		return
+	case err != nil:
+		log.Println("Failed to update reaction:", err)
+		return
+	}
+
+	//Reacting twice with the same emoji, or removing one that isn't there, changes nothing
+	if !changed {
+		return
+	}
+
+	//Counts are the same for everyone; clients work out "reacted" from user_id
+	counts, err := helpers.ReactionCounts(ctx, []primitive.ObjectID{m.ID}, "")
+	if err != nil {
+		log.Println("Failed to count reactions:", err)
+		return
+	}
+
+	response, err := json.Marshal(map[string]interface{}{
+		"type":      "reaction_updated",
+		"convoID":   convo.ConversationID,
+		"messageID": m.ID,
+		"user_id":   c.UserID,
+		"emoji":     msg.Emoji,
+		"action":    msg.Type,
+		"reactions": counts[m.ID],
+	})
+	if err != nil {
+		log.Println("Failed to marshal message:", err)
+		return
+	}
+
+	BroadcastToConversation(convo, response)
+}
+
+// findMessage loads msg.MessageID and its conversation, provided the client is a member of it
+// and the message hasn't expired.
+// Anything else is reported to the client as a missing message.
+func (c *Client) findMessage(ctx context.Context, msg WSMessage) (models.Message, models.Conversation, bool) {
+	var m models.Message
+	var convo models.Conversation
+
+	messageID, err := primitive.ObjectIDFromHex(msg.MessageID)
+	if err != nil {
+		c.sendError(msg.Type, "Message not found")
+		return m, convo, false
+	}
+
+	messageCollection := config.OpenCollection("message")
+	if err := messageCollection.FindOne(ctx, bson.M{"_id": messageID}).Decode(&m); err != nil {
+		c.sendError(msg.Type, "Message not found")
+		return m, convo, false
+	}
+
+	//Expired messages are only still here until the sweeper gets to them
+	if m.ExpiresAt != nil && !m.ExpiresAt.After(time.Now()) {
+		c.sendError(msg.Type, "Message not found")
+		return m, convo, false
+	}
+
+	convoCollection := config.OpenCollection("conversation")
+	if err := convoCollection.FindOne(ctx, bson.M{"conversationID": m.ConversationID}).Decode(&convo); err != nil {
+		c.sendError(msg.Type, "Message not found")
+		return m, convo, false
+	}
+
+	isMember, err := helpers.IsMember(ctx, convo, c.UserID)
+	if err != nil {
+		log.Println("Failed to check membership:", err)
+		return m, convo, false
+	}
+	if !isMember {
+		c.sendError(msg.Type, "Message not found")
+		return m, convo, false
+	}
+
+	return m, convo, true
+}
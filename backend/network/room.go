@@ -8,7 +8,7 @@ import (
 	"sync"
 )
 
-// Global Online Client tracker variable
+// Global Online Client tracker variable, keyed by user ID
 var onlineClients = make(map[string]*Client)
 var onlineMu sync.Mutex
 
@@ -52,7 +52,7 @@ func (r *Room) Run() {
 				continue
 			}
 			for c := range r.Clients {
-				c.Receive <- jsMsg
+				c.send(jsMsg)
 			}
 		}
 	}
@@ -88,14 +88,15 @@ func GetRoom(name string) *Room {
 	return r
 }
 
-func GenerateRoomName(username string) string {
-	return "Room_" + username
+func GenerateRoomName(userID string) string {
+	return "Room_" + userID
 }
 
 func (r *Room) ServeHttp(w http.ResponseWriter, req *http.Request) {
+	userID := req.URL.Query().Get("user_id")
 	username := req.URL.Query().Get("username")
-	if username == "" {
-		http.Error(w, "username required", http.StatusBadRequest)
+	if userID == "" || username == "" {
+		http.Error(w, "user_id and username required", http.StatusBadRequest)
 		return
 	}
 
@@ -109,24 +110,20 @@ func (r *Room) ServeHttp(w http.ResponseWriter, req *http.Request) {
 		Socket:   socket,
 		Receive:  make(chan []byte, messageBufferSize),
 		Room:     r,
+		UserID:   userID,
 		Username: username,
 	}
 
 	onlineMu.Lock()
-	onlineClients[client.Username] = client
+	onlineClients[client.UserID] = client
 	onlineMu.Unlock()
 
 	r.Join <- client
-	defer func() {
-		r.Leave <- client
-		onlineMu.Lock()
-		delete(onlineClients, username)
-		onlineMu.Unlock()
-	}()
 
 	go client.Write()
 
 	client.ReceivePendingFriendRequest() //Working
+	client.LoadOutgoingFriendRequests()
 	client.LoadAllFriends() //Working
 	client.LoadAllMessage() //Working
 
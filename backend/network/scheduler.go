@@ -0,0 +1,106 @@
+package network
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/shjung-dev/ChatApplication/backend/config"
+	"github.com/shjung-dev/ChatApplication/backend/helpers"
+	"github.com/shjung-dev/ChatApplication/backend/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// StartScheduler sends scheduled messages once they are due, checking every interval.
+func StartScheduler(interval time.Duration) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	if err := helpers.FailInterruptedScheduled(ctx); err != nil {
+		log.Println("Failed to recover scheduled messages:", err)
+	}
+	cancel()
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			sendDueMessages()
+		}
+	}()
+}
+
+func sendDueMessages() {
+	for {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+
+		s, err := helpers.ClaimDueScheduled(ctx)
+		if err != nil {
+			if err != mongo.ErrNoDocuments {
+				log.Println("Failed to fetch scheduled messages:", err)
+			}
+			cancel()
+			return
+		}
+
+		sendScheduled(ctx, s)
+		cancel()
+	}
+}
+
+// sendScheduled posts s exactly as if its author had sent it from their socket just now.
+func sendScheduled(ctx context.Context, s models.ScheduledMessage) {
+	var sendErr string
+
+	_, err := sendScheduledMessage(ctx, s)
+	if reason, ok := refusal(err); ok {
+		sendErr = reason
+	} else if err != nil {
+		log.Println("Failed to send scheduled message", s.ScheduleID+":", err)
+		sendErr = "Something went wrong while sending"
+	}
+
+	if err := helpers.FinishScheduled(ctx, s, sendErr); err != nil {
+		log.Println("Failed to update scheduled message", s.ScheduleID+":", err)
+	}
+
+	if sendErr != "" {
+		s.Status = models.ScheduledFailed
+		s.Error = sendErr
+		NotifyUser(s.UserID, map[string]interface{}{
+			"type":      "scheduled_failed",
+			"scheduled": s,
+		})
+	}
+}
+
+func sendScheduledMessage(ctx context.Context, s models.ScheduledMessage) (models.Message, error) {
+	//The author may have been renamed since scheduling
+	sender, err := helpers.UserRefByID(ctx, s.UserID)
+	if err == mongo.ErrNoDocuments {
+		return models.Message{}, refused("Your account no longer exists")
+	}
+	if err != nil {
+		return models.Message{}, err
+	}
+
+	var convo models.Conversation
+
+	convoCollection := config.OpenCollection("conversation")
+	err = convoCollection.FindOne(ctx, bson.M{"conversationID": s.ConversationID}).Decode(&convo)
+	if err == mongo.ErrNoDocuments {
+		return models.Message{}, refused("Conversation not found")
+	}
+	if err != nil {
+		return models.Message{}, err
+	}
+
+	return sendMessage(ctx, sender, convo, WSMessage{
+		Type:           "message",
+		ConvoID:        s.ConversationID,
+		MessageContent: s.Content,
+		Attachments:    s.Attachments,
+		ReplyTo:        s.ReplyTo,
+		ThreadID:       s.ThreadID,
+	})
+}
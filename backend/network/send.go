@@ -0,0 +1,202 @@
+package network
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/shjung-dev/ChatApplication/backend/config"
+	"github.com/shjung-dev/ChatApplication/backend/helpers"
+	"github.com/shjung-dev/ChatApplication/backend/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// refusedError is a message that wasn't sent for a reason the sender should be told about.
+type refusedError struct {
+	reason string
+}
+
+func (e *refusedError) Error() string {
+	return e.reason
+}
+
+func refused(reason string) error {
+	return &refusedError{reason: reason}
+}
+
+// refusal returns the reason to show the sender if err is a refusal.
+func refusal(err error) (string, bool) {
+	var r *refusedError
+	if errors.As(err, &r) {
+		return r.reason, true
+	}
+	return "", false
+}
+
+// sendMessage posts msg from sender into an existing conversation: it checks that the
+// sender may post there, stores the message and fans it out. Live messages from a socket
+// and scheduled messages both go through here.
+func sendMessage(ctx context.Context, sender models.UserRef, convo models.Conversation, msg WSMessage) (models.Message, error) {
+	var m models.Message
+
+	if err := checkCanPost(ctx, sender, convo); err != nil {
+		return m, err
+	}
+
+	replyTo, threadRoot, err := resolveReply(ctx, msg, convo)
+	if err != nil {
+		return m, err
+	}
+
+	now := time.Now()
+	m = models.Message{
+		ID:             primitive.NewObjectID(),
+		ConversationID: convo.ConversationID,
+		SenderID:       sender.ID,
+		SenderUserName: sender.Username,
+		Content:        msg.MessageContent,
+		ReplyTo:        replyTo,
+		CreatedAt:      now,
+		ExpiresAt:      convo.ExpiresAt(now),
+	}
+	if threadRoot != nil {
+		m.ThreadID = &threadRoot.ID
+	}
+	addMentions(ctx, sender, &m, convo)
+
+	//Uploads must already be in this conversation
+	if len(msg.Attachments) > 0 {
+		m.Attachments, err = helpers.ClaimAttachments(ctx, msg.Attachments, convo.ConversationID, sender.ID, m.ID)
+		if err != nil {
+			releaseAttachments(ctx, m.ID)
+			return m, err
+		}
+	}
+
+	if threadRoot != nil {
+		err = publishThreadReply(ctx, convo, *threadRoot, m)
+	} else {
+		err = publishMessage(ctx, convo, m)
+	}
+	if err != nil {
+		if len(m.Attachments) > 0 {
+			releaseAttachments(ctx, m.ID)
+		}
+		return m, err
+	}
+	notifyMentions(convo, m)
+
+	//Images are shown as placeholders until the workers publish message_updated
+	QueueMedia(m.Attachments)
+	return m, nil
+}
+
+// releaseAttachments gives back the uploads claimed by a message that wasn't stored.
+func releaseAttachments(ctx context.Context, messageID primitive.ObjectID) {
+	if err := helpers.ReleaseAttachments(ctx, messageID); err != nil {
+		log.Println("Failed to release attachments:", err)
+	}
+}
+
+// checkCanPost refuses senders who may not post into convo.
+func checkCanPost(ctx context.Context, sender models.UserRef, convo models.Conversation) error {
+	//Only members can post, and only admins can post into a broadcast channel
+	isMember, err := helpers.IsMember(ctx, convo, sender.ID)
+	if err != nil {
+		return err
+	}
+	if !isMember {
+		return refused("You are not a member of this conversation")
+	}
+	if convo.IsChannel() && !convo.IsAdmin(sender.ID) {
+		return refused("Only channel admins can post in this channel")
+	}
+	if convo.Kind() == models.ConversationDirect {
+		blocked, err := blockedAmong(ctx, sender.ID, convo.ParticipantIDs)
+		if err != nil {
+			return err
+		}
+		if blocked {
+			return refused("You cannot message this user")
+		}
+	}
+	return nil
+}
+
+// publishMessage stores m, bumps its conversation's activity and pushes m to the online participants.
+func publishMessage(ctx context.Context, convo models.Conversation, m models.Message) error {
+	convoCollection := config.OpenCollection("conversation")
+	result, err := convoCollection.UpdateOne(ctx,
+		bson.M{"conversationID": convo.ConversationID},
+		bson.M{"$set": bson.M{"lastMessageAt": m.CreatedAt}},
+	)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return refused("Conversation not found")
+	}
+	convo.LastMessageAt = m.CreatedAt
+
+	messageCollection := config.OpenCollection("message")
+	if _, err := messageCollection.InsertOne(ctx, m); err != nil {
+		return err
+	}
+
+	//Send back payload to all the participants of this convo
+	response, err := json.Marshal(map[string]interface{}{
+		"type":    "message",
+		"convo":   convo,
+		"message": m,
+	})
+	if err != nil {
+		return err
+	}
+
+	BroadcastToConversation(convo, response)
+	return nil
+}
+
+// blockedAmong reports whether userID has blocked, or been blocked by, any of userIDs.
+func blockedAmong(ctx context.Context, userID string, userIDs []string) (bool, error) {
+	blocked, err := helpers.BlockedUserIDs(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+
+	for _, b := range blocked {
+		for _, u := range userIDs {
+			if b == u {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+// handleMessage is the "message" websocket event. Without an existing conversation it starts one.
+func (c *Client) handleMessage(msg WSMessage) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	//Check if this convo exists
+	convoCollection := config.OpenCollection("conversation")
+	var convo models.Conversation
+	err := convoCollection.FindOne(ctx, bson.M{"conversationID": msg.ConvoID}).Decode(&convo)
+
+	if err != nil {
+		c.startConversation(ctx, msg)
+		return
+	}
+
+	if _, err := sendMessage(ctx, c.Ref(), convo, msg); err != nil {
+		if reason, ok := refusal(err); ok {
+			c.sendError(msg.Type, reason)
+			return
+		}
+		log.Println("Failed to send message:", err)
+	}
+}
@@ -0,0 +1,67 @@
+package network
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+
+	"github.com/shjung-dev/ChatApplication/backend/config"
+	"github.com/shjung-dev/ChatApplication/backend/helpers"
+	"github.com/shjung-dev/ChatApplication/backend/models"
+)
+
+// resolveReply checks the quoted message and thread root a new message points at.
+func resolveReply(ctx context.Context, msg WSMessage, convo models.Conversation) (*models.ReplyPreview, *models.Message, error) {
+	var replyTo *models.ReplyPreview
+	var root *models.Message
+
+	if msg.ReplyTo != "" {
+		preview, err := helpers.ReplyPreviewFor(ctx, convo.ConversationID, msg.ReplyTo)
+		if err != nil {
+			return nil, nil, refused(err.Error())
+		}
+		replyTo = preview
+	}
+
+	if msg.ThreadID != "" {
+		m, err := helpers.FindThreadRoot(ctx, convo.ConversationID, msg.ThreadID)
+		if err != nil {
+			return nil, nil, refused(err.Error())
+		}
+		root = &m
+	}
+
+	return replyTo, root, nil
+}
+
+// publishThreadReply stores a reply in root's side thread, updates the root's summary and
+// tells the conversation about both. Replies stay out of the main timeline, so unlike
+// publishMessage this neither sends a "message" event nor bumps the conversation's activity.
+func publishThreadReply(ctx context.Context, convo models.Conversation, root models.Message, reply models.Message) error {
+	messageCollection := config.OpenCollection("message")
+	if _, err := messageCollection.InsertOne(ctx, reply); err != nil {
+		return err
+	}
+
+	//The reply is stored, so a stale reply count is not worth failing the send over
+	summary, err := helpers.RecordThreadReply(ctx, root, reply)
+	if err != nil {
+		log.Println("Failed to update thread:", err)
+		return nil
+	}
+
+	response, err := json.Marshal(map[string]interface{}{
+		"type":      "thread_updated",
+		"convoID":   convo.ConversationID,
+		"messageID": root.ID,
+		"thread":    summary,
+		"reply":     reply,
+	})
+	if err != nil {
+		log.Println("Failed to marshal message:", err)
+		return nil
+	}
+
+	BroadcastToConversation(convo, response)
+	return nil
+}
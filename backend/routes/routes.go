@@ -10,14 +10,60 @@ func SetUpRoutes(r *gin.Engine) {
 	r.POST("/login", controllers.Login())
 	r.POST("/signup", controllers.Signup())
 	r.POST("/refresh", controllers.RefreshTokenHandler())
+	r.GET("/export/:token", controllers.DownloadExport())
 
 	protected := r.Group("/")
 
 	protected.Use(middleware.Authenticate())
 	{
 		protected.GET("/user/:receiver", controllers.SearchUser())
+		protected.GET("/users/search", controllers.SearchUsers())
+		protected.GET("/profile", controllers.GetMyProfile())
+		protected.PUT("/profile", controllers.UpdateProfile())
+		protected.GET("/profile/:username", controllers.GetProfile())
+		protected.DELETE("/account", controllers.DeleteAccount())
+		protected.PUT("/account/username", controllers.ChangeUsername())
+		protected.POST("/account/export", controllers.RequestExport())
+		protected.GET("/account/export/:jobID", controllers.ExportStatus())
 		protected.POST("/accept/:username", controllers.Accept())
 		protected.POST("/reject/:receiver", controllers.Reject())
 		protected.POST("/remove/:username", controllers.Remove())
+		protected.POST("/cancel/:receiver", controllers.Cancel())
+		protected.GET("/requests/outgoing", controllers.OutgoingRequests())
+		protected.GET("/friends/suggestions", controllers.FriendSuggestions())
+
+		protected.GET("/blocks", controllers.ListBlocked())
+		protected.POST("/block/:username", controllers.Block())
+		protected.DELETE("/block/:username", controllers.Unblock())
+
+		protected.POST("/conversation/:convoID/invites", controllers.CreateInvite())
+		protected.GET("/conversation/:convoID/invites", controllers.ListInvites())
+		protected.DELETE("/conversation/:convoID/invites", controllers.RevokeAllInvites())
+		protected.GET("/invite/:token", controllers.PreviewInvite())
+		protected.POST("/invite/:token/join", controllers.JoinInvite())
+		protected.DELETE("/invite/:token", controllers.RevokeInvite())
+
+		protected.POST("/conversation/:convoID/attachments", controllers.UploadAttachment())
+		protected.GET("/attachment/:attachmentID", controllers.DownloadAttachment())
+		protected.GET("/attachment/:attachmentID/thumbnail", controllers.DownloadThumbnail())
+
+		protected.GET("/message/:messageID/thread", controllers.GetThread())
+		protected.GET("/messages/search", controllers.SearchMessages())
+
+		protected.POST("/conversation/:convoID/scheduled", controllers.ScheduleMessage())
+		protected.GET("/scheduled", controllers.ListScheduledMessages())
+		protected.PUT("/scheduled/:scheduleID", controllers.UpdateScheduledMessage())
+		protected.DELETE("/scheduled/:scheduleID", controllers.CancelScheduledMessage())
+
+		protected.PUT("/conversation/:convoID/expiry", controllers.SetMessageExpiry())
+
+		protected.GET("/channels", controllers.ChannelDirectory())
+		protected.POST("/channels", controllers.CreateChannel())
+		protected.PUT("/channel/:convoID", controllers.UpdateChannel())
+		protected.POST("/channel/:convoID/join", controllers.JoinChannel())
+		protected.POST("/channel/:convoID/leave", controllers.LeaveChannel())
+		protected.POST("/channel/:convoID/members", controllers.AddChannelMembers())
+		protected.DELETE("/channel/:convoID/members/:username", controllers.RemoveChannelMember())
+		protected.POST("/channel/:convoID/admins/:username", controllers.AddChannelAdmin())
 	}
 }
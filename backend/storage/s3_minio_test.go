@@ -0,0 +1,87 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+)
+
+// TestS3StoreMinIO runs the store against a real MinIO. It is skipped unless MINIO_ENDPOINT is set:
+//
+//	docker run -p 9000:9000 minio/minio server /data
+//	MINIO_ENDPOINT=http://localhost:9000 go test ./storage -run MinIO
+//
+// MINIO_ACCESS_KEY and MINIO_SECRET_KEY default to MinIO's minioadmin, and MINIO_BUCKET
+// (created if missing) to chat-test.
+func TestS3StoreMinIO(t *testing.T) {
+	endpoint := os.Getenv("MINIO_ENDPOINT")
+	if endpoint == "" {
+		t.Skip("MINIO_ENDPOINT not set")
+	}
+
+	s := NewS3Store(endpoint, os.Getenv("MINIO_REGION"), envOr("MINIO_BUCKET", "chat-test"),
+		envOr("MINIO_ACCESS_KEY", "minioadmin"), envOr("MINIO_SECRET_KEY", "minioadmin"))
+	ctx := context.Background()
+
+	createBucket(t, ctx, s)
+
+	key := "attachments/convo/a file+name$.txt"
+	body := []byte("hello from the s3 store")
+
+	if err := s.Put(ctx, key, bytes.NewReader(body), int64(len(body)), "text/plain"); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+
+	r, err := s.Get(ctx, key)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	got, err := io.ReadAll(r)
+	r.Close()
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if !bytes.Equal(got, body) {
+		t.Errorf("Get = %q, want %q", got, body)
+	}
+
+	if err := s.Delete(ctx, key); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := s.Get(ctx, key); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get after Delete = %v, want ErrNotFound", err)
+	}
+	if err := s.Delete(ctx, key); err != nil {
+		t.Errorf("Delete of a missing key = %v, want nil", err)
+	}
+}
+
+func createBucket(t *testing.T, ctx context.Context, s *S3Store) {
+	t.Helper()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, s.Endpoint+"/"+s.Bucket, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := s.do(req)
+	if err != nil {
+		if strings.Contains(err.Error(), "BucketAlreadyOwnedByYou") {
+			return
+		}
+		t.Fatalf("create bucket: %v", err)
+	}
+	resp.Body.Close()
+}
+
+func envOr(name string, fallback string) string {
+	if v := os.Getenv(name); v != "" {
+		return v
+	}
+	return fallback
+}